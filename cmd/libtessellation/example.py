@@ -0,0 +1,70 @@
+"""Steps a glider through libtessellation with ctypes.
+
+Build the library first, from the tessellation directory:
+
+    go build -buildmode=c-shared -o libtessellation.so ./cmd/libtessellation
+    python3 cmd/libtessellation/example.py
+"""
+import ctypes
+
+lib = ctypes.CDLL("./libtessellation.so")
+lib.tess_new.argtypes = [ctypes.c_char_p, ctypes.c_char_p, ctypes.c_int32, ctypes.c_int32, ctypes.c_char_p, ctypes.c_size_t]
+lib.tess_new.restype = ctypes.c_int64
+lib.tess_free.argtypes = [ctypes.c_int64]
+lib.tess_last_error.argtypes = [ctypes.c_int64, ctypes.c_char_p, ctypes.c_size_t]
+lib.tess_last_error.restype = ctypes.c_int64
+lib.tess_step.argtypes = [ctypes.c_int64, ctypes.c_int32]
+lib.tess_generation.argtypes = [ctypes.c_int64]
+lib.tess_generation.restype = ctypes.c_int64
+lib.tess_size.argtypes = [ctypes.c_int64, ctypes.POINTER(ctypes.c_int32), ctypes.POINTER(ctypes.c_int32)]
+lib.tess_live_cells.argtypes = [ctypes.c_int64, ctypes.POINTER(ctypes.c_int32), ctypes.c_size_t]
+lib.tess_live_cells.restype = ctypes.c_int64
+lib.tess_frame.argtypes = [ctypes.c_int64, ctypes.POINTER(ctypes.c_uint8), ctypes.c_size_t]
+lib.tess_frame.restype = ctypes.c_int64
+
+# the mask needs a ring of cells outside the tile for the border
+GRID = b"""
+..........
+.oXoooooo.
+.ooXooooo.
+.XXXooooo.
+.oooooooo.
+.oooooooo.
+.oooooooo.
+.oooooooo.
+.oooooooo.
+..........
+"""
+
+
+def check(h, code):
+    if code < 0:
+        msg = ctypes.create_string_buffer(lib.tess_last_error(h, None, 0))
+        lib.tess_last_error(h, msg, len(msg))
+        raise RuntimeError(msg.value.decode())
+    return code
+
+
+msg = ctypes.create_string_buffer(256)
+h = lib.tess_new(GRID, b"B3/S23", 8, 8, msg, len(msg))
+if h == 0:
+    raise RuntimeError(msg.value.decode())
+try:
+    rows, cols = ctypes.c_int32(), ctypes.c_int32()
+    check(h, lib.tess_size(h, ctypes.byref(rows), ctypes.byref(cols)))
+
+    for _ in range(4):
+        check(h, lib.tess_step(h, 8))
+        n = check(h, lib.tess_live_cells(h, None, 0))
+        cells = (ctypes.c_int32 * (2 * n))()
+        check(h, lib.tess_live_cells(h, cells, n))
+        print("generation", lib.tess_generation(h), list(zip(cells[0::2], cells[1::2])))
+
+    size = check(h, lib.tess_frame(h, None, 0))
+    frame = (ctypes.c_uint8 * size)()
+    check(h, lib.tess_frame(h, frame, size))
+    stride = (cols.value + 7) // 8
+    for r in range(rows.value):
+        print("".join("#" if frame[r * stride + c // 8] & (0x80 >> c % 8) else "." for c in range(cols.value)))
+finally:
+    lib.tess_free(h)
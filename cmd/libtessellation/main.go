@@ -0,0 +1,314 @@
+// Command libtessellation builds the pattern package as a C shared library,
+// for hosts that embed the simulation instead of running the command:
+//
+//	go build -buildmode=c-shared -o libtessellation.so ./cmd/libtessellation
+//
+// which also writes libtessellation.h. example.py drives it with ctypes.
+//
+// A simulation is reached through a handle, an integer above 0 returned by
+// tess_new and valid until tess_free. The handles are independent: calls
+// with different handles may run at the same time, calls with the same
+// handle are serialized.
+//
+// Memory: the library never hands out memory, so the caller has nothing
+// to free but the handles. tess_live_cells, tess_frame and the error
+// messages are written into buffers the caller owns, and the functions
+// return the size they need, like snprintf, so a call with a size of 0
+// asks for it. Messages are cut to fit and always end in a NUL.
+//
+// Errors: the functions that can fail return a negative TESS_E code.
+// tess_new writes its message into the buffer it is given, the others
+// keep theirs with the handle for tess_last_error.
+package main
+
+/*
+#include <stddef.h>
+#include <stdint.h>
+
+enum {
+	TESS_OK = 0,
+	TESS_EHANDLE = -1, // no such handle
+	TESS_EINVAL = -2,  // bad argument
+};
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/fidelcoria/tessellation/pattern"
+)
+
+// handle is a simulation and the last error of the calls made with it.
+type handle struct {
+	mu  sync.Mutex
+	sim *pattern.Simulation
+	pat *pattern.Pattern
+
+	// lastErr is the message of the last call, "" if it succeeded.
+	lastErr string
+}
+
+// setErr keeps the formatted message as the last error of h and returns code.
+// h.mu must be held.
+func (h *handle) setErr(code C.int, format string, args ...interface{}) C.int {
+	h.lastErr = fmt.Sprintf(format, args...)
+	return code
+}
+
+// clearErr records that the last call with h succeeded. h.mu must be held.
+func (h *handle) clearErr() {
+	h.lastErr = ""
+}
+
+// copyString writes msg to the size bytes at buf, cut to fit and ending in
+// a NUL, and returns the size it needs, NUL included. buf may be NULL when
+// size is 0.
+func copyString(buf *C.char, size C.size_t, msg string) C.int64_t {
+	if buf != nil && size > 0 {
+		out := unsafe.Slice((*byte)(unsafe.Pointer(buf)), size)
+		n := copy(out[:size-1], msg)
+		out[n] = 0
+	}
+	return C.int64_t(len(msg) + 1)
+}
+
+// The registry maps handle numbers to handles; 0 is never a handle.
+var (
+	registryMu sync.Mutex
+	registry             = map[C.int64_t]*handle{}
+	nextHandle C.int64_t = 1
+)
+
+// lookup returns the handle for id, or nil if it is not in the registry.
+func lookup(id C.int64_t) *handle {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return registry[id]
+}
+
+// tess_new makes a simulation from a combined grid, see pattern.LoadCombined,
+// with the live cells of the grid as generation 0. rule is a birth/survival
+// rule like "B3/S23", see pattern.ParseRule; NULL or "" is Conway's.
+// With latticeRows and latticeCols both 0 the tile is an island with dead
+// cells around it, see pattern.NewFinite; otherwise it repeats every
+// latticeRows rows down and latticeCols columns across, see pattern.NewLattice.
+// It returns the new handle, or 0 with the error written to the errSize
+// bytes at errBuf, see copyString; errBuf may be NULL when errSize is 0.
+//
+//export tess_new
+func tess_new(grid *C.char, rule *C.char, latticeRows, latticeCols C.int32_t, errBuf *C.char, errSize C.size_t) C.int64_t {
+	if grid == nil {
+		copyString(errBuf, errSize, "tess_new: grid is NULL")
+		return 0
+	}
+	sim, pat, err := newSimulation(C.GoString(grid), C.GoString(rule), int(latticeRows), int(latticeCols))
+	if err != nil {
+		copyString(errBuf, errSize, fmt.Sprintf("tess_new: %v", err))
+		return 0
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	id := nextHandle
+	nextHandle++
+	registry[id] = &handle{sim: sim, pat: pat}
+	return id
+}
+
+// newSimulation is tess_new with Go arguments.
+func newSimulation(grid, rule string, latticeRows, latticeCols int) (*pattern.Simulation, *pattern.Pattern, error) {
+	mask, seed, lines, err := pattern.LoadCombinedLines(strings.NewReader(grid))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var opts []pattern.Option
+	if rule != "" {
+		r, err := pattern.ParseRule(rule)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, pattern.WithRule(r))
+	}
+
+	var pat *pattern.Pattern
+	if latticeRows == 0 && latticeCols == 0 {
+		pat, err = pattern.NewFinite(mask, opts...)
+	} else {
+		pat, err = pattern.NewLattice(mask, pattern.Offset{Row: latticeRows}, pattern.Offset{Col: latticeCols}, opts...)
+	}
+	if err != nil {
+		return nil, nil, pattern.LocateCombined(err, lines)
+	}
+
+	tile, err := pattern.TileFrom(seed)
+	if err != nil {
+		return nil, nil, err
+	}
+	sim, err := pattern.NewSimulation(pat, tile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sim, pat, nil
+}
+
+// tess_free frees the handle and everything that belongs to it.
+// The handle number is not given out again.
+//
+//export tess_free
+func tess_free(id C.int64_t) C.int {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if registry[id] == nil {
+		return C.TESS_EHANDLE
+	}
+	delete(registry, id)
+	return C.TESS_OK
+}
+
+// tess_last_error writes the message of the last call with the handle to
+// the size bytes at buf, see copyString, and returns the size it needs.
+// The message is empty, and the size 1, if the last call succeeded.
+// It returns TESS_EHANDLE for a handle that doesn't exist.
+//
+//export tess_last_error
+func tess_last_error(id C.int64_t, buf *C.char, size C.size_t) C.int64_t {
+	h := lookup(id)
+	if h == nil {
+		return C.TESS_EHANDLE
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return copyString(buf, size, h.lastErr)
+}
+
+// tess_step evolves the simulation n generations.
+//
+//export tess_step
+func tess_step(id C.int64_t, n C.int32_t) C.int {
+	h := lookup(id)
+	if h == nil {
+		return C.TESS_EHANDLE
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n < 0 {
+		return h.setErr(C.TESS_EINVAL, "tess_step: can't step %d generations", n)
+	}
+	h.clearErr()
+	for i := 0; i < int(n); i++ {
+		h.sim.Step()
+	}
+	return C.TESS_OK
+}
+
+// tess_generation returns the number of the current generation,
+// or TESS_EHANDLE.
+//
+//export tess_generation
+func tess_generation(id C.int64_t) C.int64_t {
+	h := lookup(id)
+	if h == nil {
+		return C.TESS_EHANDLE
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.clearErr()
+	return C.int64_t(h.sim.Generation())
+}
+
+// tess_size writes the rows and columns of the tile to rows and cols.
+//
+//export tess_size
+func tess_size(id C.int64_t, rows, cols *C.int32_t) C.int {
+	h := lookup(id)
+	if h == nil {
+		return C.TESS_EHANDLE
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if rows == nil || cols == nil {
+		return h.setErr(C.TESS_EINVAL, "tess_size: rows and cols must not be NULL")
+	}
+	h.clearErr()
+	*rows, *cols = C.int32_t(h.pat.Rows()), C.int32_t(h.pat.Cols())
+	return C.TESS_OK
+}
+
+// tess_live_cells writes the live cells of the current generation to cells
+// as row, column pairs in id order, as many as fit in n pairs, and returns
+// the number of live cells. cells may be NULL when n is 0.
+//
+//export tess_live_cells
+func tess_live_cells(id C.int64_t, cells *C.int32_t, n C.size_t) C.int64_t {
+	h := lookup(id)
+	if h == nil {
+		return C.TESS_EHANDLE
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if cells == nil && n > 0 {
+		return C.int64_t(h.setErr(C.TESS_EINVAL, "tess_live_cells: cells is NULL"))
+	}
+	h.clearErr()
+	var out []C.int32_t
+	if n > 0 {
+		out = unsafe.Slice(cells, 2*n)
+	}
+	live := 0
+	h.pat.EachAlive(h.sim.Current().Grid(), func(_ int, c pattern.Cell) bool {
+		if live < int(n) {
+			out[2*live], out[2*live+1] = C.int32_t(c.Row), C.int32_t(c.Col)
+		}
+		live++
+		return true
+	})
+	return C.int64_t(live)
+}
+
+// tess_frame writes the current generation to buf as a 1-bit image of the
+// whole tile, one bit per cell with live tile cells set. Rows are packed
+// most significant bit first and padded to a whole byte, as in the body of
+// a P4 PBM. It returns the size of the image in bytes and writes it only
+// if it fits in size bytes. buf may be NULL when size is 0.
+//
+//export tess_frame
+func tess_frame(id C.int64_t, buf *C.uint8_t, size C.size_t) C.int64_t {
+	h := lookup(id)
+	if h == nil {
+		return C.TESS_EHANDLE
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if buf == nil && size > 0 {
+		return C.int64_t(h.setErr(C.TESS_EINVAL, "tess_frame: buf is NULL"))
+	}
+	h.clearErr()
+	stride := (h.pat.Cols() + 7) / 8
+	need := stride * h.pat.Rows()
+	if int(size) < need {
+		return C.int64_t(need)
+	}
+
+	bits := unsafe.Slice((*byte)(unsafe.Pointer(buf)), need)
+	for i := range bits {
+		bits[i] = 0
+	}
+	h.pat.EachAlive(h.sim.Current().Grid(), func(_ int, c pattern.Cell) bool {
+		bits[c.Row*stride+c.Col/8] |= 0x80 >> uint(c.Col%8)
+		return true
+	})
+	return C.int64_t(need)
+}
+
+// main is required by -buildmode=c-shared and never runs.
+func main() {}
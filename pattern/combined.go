@@ -0,0 +1,131 @@
+package pattern
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Tokens of the combined grid format read by LoadCombined.
+const (
+	outsideToken = "." // not part of the tile
+	deadToken    = "o" // tile cell, dead
+	aliveToken   = "X" // tile cell, alive
+)
+
+// LoadCombined reads a grid that encodes both a mask for New and a seed
+// tile: "." is outside the tile, "o" a dead tile cell and "X" a live one.
+// The fields of a line are either comma separated (CSV) or one character
+// each (plain text). Blank lines are skipped. Errors about the grid wrap
+// ErrCombined and give the line and column in the file, counting from 1.
+func LoadCombined(r io.Reader) (mask, tile [][]bool, err error) {
+	mask, tile, _, err = LoadCombinedLines(r)
+	return mask, tile, err
+}
+
+// LoadCombinedLines is LoadCombined that also returns the file line of
+// each row of the grid, counting from 1, for LocateCombined.
+func LoadCombinedLines(r io.Reader) (mask, tile [][]bool, lines []int, err error) {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if text == "" {
+			continue
+		}
+
+		var fields []string
+		if strings.Contains(text, ",") {
+			fields = strings.Split(text, ",")
+		} else {
+			fields = strings.Split(text, "")
+		}
+		if len(mask) > 0 && len(fields) != len(mask[0]) {
+			return nil, nil, nil, fmt.Errorf("LoadCombined: pattern: %w: line %d has %d columns, expected %d",
+				ErrCombined, line, len(fields), len(mask[0]))
+		}
+
+		maskRow, tileRow := make([]bool, len(fields)), make([]bool, len(fields))
+		for col, field := range fields {
+			switch strings.TrimSpace(field) {
+			case outsideToken:
+			case deadToken:
+				maskRow[col] = true
+			case aliveToken:
+				maskRow[col], tileRow[col] = true, true
+			default:
+				return nil, nil, nil, fmt.Errorf("LoadCombined: pattern: %w: line %d, column %d: unexpected %q, want %q, %q or %q",
+					ErrCombined, line, col+1, field, outsideToken, deadToken, aliveToken)
+			}
+		}
+		mask, tile = append(mask, maskRow), append(tile, tileRow)
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, nil, fmt.Errorf("LoadCombined: pattern: %w", err)
+	}
+	if len(mask) == 0 {
+		return nil, nil, nil, fmt.Errorf("LoadCombined: pattern: %w: empty grid", ErrCombined)
+	}
+
+	return mask, tile, lines, nil
+}
+
+// LocateCombined rewrites the mask positions named by err, an error from
+// New, NewLattice or NewFinite for a mask read by LoadCombinedLines, as
+// the line and column in the file, counting from 1, so they agree with the
+// errors of LoadCombined. errors.Is and errors.As still see err.
+func LocateCombined(err error, lines []int) error {
+	var pe positionError
+	if !errors.As(err, &pe) {
+		return err
+	}
+	at := func(c Cell) string {
+		if c.Row < 0 || c.Row >= len(lines) {
+			return fmt.Sprintf("(r:%v c:%v, outside the file)", c.Row, c.Col)
+		}
+		return fmt.Sprintf("(line %d, column %d)", lines[c.Row], c.Col+1)
+	}
+	msg := strings.Replace(err.Error(), pe.Error(), pe.format(at), 1)
+	return &locatedError{msg, err}
+}
+
+// locatedError is an error with its message rewritten by LocateCombined.
+type locatedError struct {
+	msg string
+	err error
+}
+
+func (e *locatedError) Error() string {
+	return e.msg
+}
+
+func (e *locatedError) Unwrap() error {
+	return e.err
+}
+
+// WriteCombined writes tile as a plain text grid that LoadCombined reads
+// back, with the pattern's mask.
+func (t *Pattern) WriteCombined(w io.Writer, tile [][]bool) error {
+	if err := t.CheckTile(tile); err != nil {
+		return fmt.Errorf("WriteCombined: pattern: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	row := make([]string, t.cols)
+	for i := 0; i < t.rows; i++ {
+		for j := range row {
+			switch {
+			case t.mask[i][j] == 0:
+				row[j] = outsideToken
+			case tile[i][j]:
+				row[j] = aliveToken
+			default:
+				row[j] = deadToken
+			}
+		}
+		fmt.Fprintln(bw, strings.Join(row, ""))
+	}
+	return bw.Flush()
+}
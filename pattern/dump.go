@@ -0,0 +1,62 @@
+package pattern
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// String returns the dump written by DumpTo.
+func (t *Pattern) String() string {
+	var b strings.Builder
+	t.DumpTo(&b)
+	return b.String()
+}
+
+// DumpTo writes a readable description of what New computed: the rule and
+// neighborhood (with its radius if not 1), the grid of cell ids, with '.'
+// outside the tile, then every border cell in row-major order with the id it
+// copies from. The output only depends on the Pattern, so it can be compared
+// between runs.
+func (t *Pattern) DumpTo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	width := len(fmt.Sprint(len(t.cells)))
+
+	fmt.Fprintf(bw, "pattern %vx%v, %v cells, rule %v, %v neighborhood", t.rows, t.cols, len(t.cells), t.GenerationsRule(), t.nbhd)
+	if t.radius > 1 {
+		fmt.Fprintf(bw, " of radius %v", t.radius)
+	}
+	bw.WriteByte('\n')
+	for _, row := range t.mask {
+		for j, id := range row {
+			if j > 0 {
+				bw.WriteByte(' ')
+			}
+			if id == 0 {
+				fmt.Fprintf(bw, "%*v", width, ".")
+			} else {
+				fmt.Fprintf(bw, "%*v", width, id)
+			}
+		}
+		bw.WriteByte('\n')
+	}
+
+	var border []Cell
+	for _, v := range t.border {
+		border = append(border, v...)
+	}
+	sort.Slice(border, func(i, j int) bool {
+		if border[i].Row != border[j].Row {
+			return border[i].Row < border[j].Row
+		}
+		return border[i].Col < border[j].Col
+	})
+	fmt.Fprintf(bw, "border, %v cells:\n", len(border))
+	for _, bc := range border {
+		fmt.Fprintf(bw, "  r:%v c:%v <- id:%v\n", bc.Row, bc.Col, t.canon[bc.Row][bc.Col])
+	}
+
+	return bw.Flush()
+}
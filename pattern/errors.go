@@ -0,0 +1,148 @@
+package pattern
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by the package, possibly wrapped. Use errors.Is to tell them apart,
+// and errors.As to get the details of OverlapError, ConflictError, CoverageError
+// and MaskEdgeError.
+var (
+	ErrEmptyMask  = errors.New("mask is empty")
+	ErrRaggedMask = errors.New("mask is not rectangular")
+	ErrMaskEdge   = errors.New("mask cells on the edge must be false")
+	ErrBadCell    = errors.New("bad cell")
+	ErrBadRule    = errors.New("bad rule")
+	ErrOverlap    = errors.New("rule causes overlap")
+	ErrConflict   = errors.New("rules conflict on border")
+	ErrUncovered  = errors.New("rules do not surround the tile")
+	ErrTileSize   = errors.New("tile does not match the pattern")
+	ErrCorrupt    = errors.New("corrupt pattern data")
+	ErrNoPeriod   = errors.New("state does not repeat")
+	ErrRuleString = errors.New("bad rulestring")
+
+	ErrBadNeighborhood = errors.New("bad neighborhood")
+	ErrBadState        = errors.New("cell state out of range")
+	ErrRuleTable       = errors.New("bad rule table")
+	ErrBadProbability  = errors.New("bad probability")
+	ErrCombined        = errors.New("bad combined grid")
+	ErrBadHistory      = errors.New("bad history length")
+	ErrBadImage        = errors.New("bad image")
+	ErrTooLarge        = errors.New("array too large")
+)
+
+// OverlapError reports a rule that moves a tile cell onto the tile itself.
+type OverlapError struct {
+	Rule     Offset
+	Row, Col int // where the copy landed
+	ID       int // id of the moved cell
+}
+
+func (e *OverlapError) Error() string {
+	return e.format(func(c Cell) string { return fmt.Sprintf("r:%v c:%v", c.Row, c.Col) })
+}
+
+func (e *OverlapError) format(at func(Cell) string) string {
+	return fmt.Sprintf("rule %v caused overlap %v, id:%v", e.Rule, at(Cell{e.Row, e.Col}), e.ID)
+}
+
+// Is makes errors.Is(err, ErrOverlap) true.
+func (e *OverlapError) Is(target error) bool {
+	return target == ErrOverlap
+}
+
+// ConflictError reports two rules that fill the same border cell from different tile cells.
+type ConflictError struct {
+	Cell  Cell
+	Rules [2]Offset
+	IDs   [2]int
+}
+
+func (e *ConflictError) Error() string {
+	return e.format(func(c Cell) string { return fmt.Sprintf("r:%v c:%v", c.Row, c.Col) })
+}
+
+func (e *ConflictError) format(at func(Cell) string) string {
+	return fmt.Sprintf("rules %v and %v conflict on border %v, ids:%v and %v",
+		e.Rules[0], e.Rules[1], at(e.Cell), e.IDs[0], e.IDs[1])
+}
+
+// Is makes errors.Is(err, ErrConflict) true.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// CoverageError lists the neighbors of tile cells that are neither in the tile nor on the border.
+type CoverageError struct {
+	// Uncovered positions in row-major order of the tile cells next to them.
+	Uncovered []Cell
+
+	// Affected maps each uncovered position to the ids of the tile cells next to it.
+	Affected map[Cell][]int
+
+	// Radius is the radius of the neighborhood, see WithRadius.
+	Radius int
+}
+
+func (e *CoverageError) Error() string {
+	return e.format(func(c Cell) string { return fmt.Sprintf("(%v, %v)", c.Row, c.Col) })
+}
+
+func (e *CoverageError) format(at func(Cell) string) string {
+	list := make([]string, len(e.Uncovered))
+	for i, n := range e.Uncovered {
+		list[i] = fmt.Sprintf("%v next to ids %v", at(n), e.Affected[n])
+	}
+	msg := fmt.Sprintf("New: pattern: rules do not surround the tile, %v positions uncovered: %v",
+		len(e.Uncovered), strings.Join(list, "; "))
+	if e.Radius > 1 {
+		msg += fmt.Sprintf("; with radius %v the copies must cover a border %v cells thick, so the translations may be too small to reach that far",
+			e.Radius, e.Radius)
+	}
+	return msg
+}
+
+// Is makes errors.Is(err, ErrUncovered) true.
+func (e *CoverageError) Is(target error) bool {
+	return target == ErrUncovered
+}
+
+// MaskEdgeError lists the tile cells of a mask that are too close to its
+// edge to have all their neighbors in the array.
+type MaskEdgeError struct {
+	// Cells on the edge in row-major order.
+	Cells []Cell
+
+	// Radius is the radius of the neighborhood, see WithRadius.
+	Radius int
+}
+
+func (e *MaskEdgeError) Error() string {
+	return e.format(func(c Cell) string { return fmt.Sprintf("(%v, %v)", c.Row, c.Col) })
+}
+
+func (e *MaskEdgeError) format(at func(Cell) string) string {
+	list := make([]string, len(e.Cells))
+	for i, c := range e.Cells {
+		list[i] = at(c)
+	}
+	if e.Radius > 1 {
+		return fmt.Sprintf("%v: with radius %v, so must the first %v rows and columns on each side: %v",
+			ErrMaskEdge, e.Radius, e.Radius, strings.Join(list, " "))
+	}
+	return fmt.Sprintf("%v: %v", ErrMaskEdge, strings.Join(list, " "))
+}
+
+// Is makes errors.Is(err, ErrMaskEdge) true.
+func (e *MaskEdgeError) Is(target error) bool {
+	return target == ErrMaskEdge
+}
+
+// positionError is an error that can name the positions in it another way,
+// see LocateCombined.
+type positionError interface {
+	error
+	format(at func(Cell) string) string
+}
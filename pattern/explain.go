@@ -0,0 +1,123 @@
+package pattern
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Explanation says why a cell gets its state in the next generation.
+type Explanation struct {
+	// Cell and ID identify the explained tile cell.
+	Cell Cell
+	ID   int
+
+	// Alive is the current state of the cell.
+	Alive bool
+
+	// Neighbors are the positions counted as neighbors in the pattern's
+	// Neighborhood, in row-major order.
+	Neighbors []Neighbor
+
+	// LiveNeighbors is the number of live Neighbors.
+	LiveNeighbors int
+
+	// Next is the state of the cell in the next generation and
+	// Reason names the rule that decided it, e.g. "lonely" or "birth".
+	Next   bool
+	Reason string
+}
+
+// Neighbor is a position next to an explained cell.
+type Neighbor struct {
+	Cell  Cell
+	Alive bool
+
+	// Source is the id of the tile cell whose value the position holds:
+	// the position's own id inside the tile, the id it is copied from on
+	// the border, or 0 if it is neither and so always counts as dead.
+	Source int
+
+	// Border is true if the value is copied from a translated tile.
+	Border bool
+
+	// Hole is true if the position is one of the pattern's Holes.
+	Hole bool
+}
+
+// ExplainCell explains the next state of tile cell c.
+// Like Evolve, it fills in the border of tile before counting neighbors,
+// and it decides the state by calling the same code Evolve uses, so the
+// two always agree.
+func (t *Pattern) ExplainCell(tile [][]bool, c Cell) (Explanation, error) {
+	if c.Row < 0 || c.Row >= t.rows || c.Col < 0 || c.Col >= t.cols || t.mask[c.Row][c.Col] == 0 {
+		return Explanation{}, fmt.Errorf("ExplainCell: r:%v c:%v is not a tile cell", c.Row, c.Col)
+	}
+	if err := t.CheckTile(tile); err != nil {
+		return Explanation{}, fmt.Errorf("ExplainCell: pattern: %w", err)
+	}
+
+	t.fillBorder(tile)
+
+	// sources of the border cells
+	borderSource := make(map[Cell]int)
+	for id, v := range t.border {
+		for _, bc := range v {
+			borderSource[bc] = id
+		}
+	}
+	isHole := make(map[Cell]bool)
+	for _, h := range t.holes {
+		isHole[h] = true
+	}
+
+	e := Explanation{Cell: c, ID: t.mask[c.Row][c.Col], Alive: tile[c.Row][c.Col]}
+	for _, d := range t.offsets.at(c.Row) {
+		r, col := c.Row+d.Row, c.Col+d.Col
+		if r < 0 || r >= t.rows || col < 0 || col >= t.cols {
+			continue
+		}
+		n := Neighbor{Cell: Cell{r, col}, Alive: tile[r][col]}
+		if id := t.mask[r][col]; id != 0 {
+			n.Source = id
+		} else if id, ok := borderSource[n.Cell]; ok {
+			n.Source, n.Border = id, true
+		} else {
+			n.Hole = isHole[n.Cell]
+		}
+		e.Neighbors = append(e.Neighbors, n)
+	}
+
+	e.LiveNeighbors = t.countNeighbors(tile, c.Row, c.Col)
+	e.Next = t.evolveCell(tile, c.Row, c.Col)
+	e.Reason = t.rule.reason(e.Alive, e.Next, e.LiveNeighbors)
+
+	return e, nil
+}
+
+// String describes the explanation over several lines.
+func (e Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cell id:%v r:%v c:%v is %v with %v live neighbors: %v, becomes %v\n",
+		e.ID, e.Cell.Row, e.Cell.Col, state(e.Alive), e.LiveNeighbors, e.Reason, state(e.Next))
+	for _, n := range e.Neighbors {
+		fmt.Fprintf(&b, "  r:%v c:%v %v", n.Cell.Row, n.Cell.Col, state(n.Alive))
+		switch {
+		case n.Border:
+			fmt.Fprintf(&b, " (border, copy of id:%v)", n.Source)
+		case n.Hole:
+			fmt.Fprint(&b, " (hole, always dead)")
+		case n.Source == 0:
+			fmt.Fprint(&b, " (outside tile, always dead)")
+		}
+		fmt.Fprintln(&b)
+	}
+	return b.String()
+}
+
+// state names a cell state.
+func state(s bool) string {
+	if s == alive {
+		return "alive"
+	}
+	return "dead"
+}
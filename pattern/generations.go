@@ -0,0 +1,131 @@
+package pattern
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GenerationsRule is a Rule whose cells don't die at once: a live cell
+// (state 1) that does not survive goes through the dying states 2, 3, ...
+// up to States()-1 and then is dead (state 0). Dying cells don't count as
+// live neighbors and can't be born. With 2 states it is the plain Rule.
+type GenerationsRule struct {
+	rule   Rule
+	states int
+}
+
+// maxStates is the number of states a uint8 cell can hold.
+const maxStates = 256
+
+// BriansBrain is the Generations rule B2/S/3: a Ready cell fires when exactly
+// two of its neighbors are Firing, a Firing cell is Refractory for the next
+// generation, and then Ready again.
+var BriansBrain = GenerationsRule{rule: Rule{birth: counts{1 << 2}}, states: 3}
+
+// The states of BriansBrain.
+const (
+	Ready uint8 = iota
+	Firing
+	Refractory
+)
+
+// ParseGenerationsRule parses a rulestring such as "B2/S345/4" (Star Wars):
+// a rulestring for ParseRule followed by the number of states, optionally
+// written with a C as in "B2/S345/C4". Without the third part the rule has
+// 2 states. Errors wrap ErrRuleString.
+func ParseGenerationsRule(s string) (GenerationsRule, error) {
+	g := GenerationsRule{states: 2}
+	rs := strings.TrimSpace(s)
+	if i := strings.LastIndex(rs, "/"); i >= 0 && strings.Count(rs, "/") == 2 {
+		n := strings.TrimPrefix(strings.TrimPrefix(rs[i+1:], "C"), "c")
+		states, err := strconv.Atoi(n)
+		if err != nil || states < 2 || states > maxStates {
+			return GenerationsRule{}, fmt.Errorf("ParseGenerationsRule: pattern: %w: %q in %q is not a number of states from 2 to %v",
+				ErrRuleString, rs[i+1:], s, maxStates)
+		}
+		g.states, rs = states, rs[:i]
+	}
+
+	r, err := ParseRule(rs)
+	if err != nil {
+		return GenerationsRule{}, err
+	}
+	g.rule = r
+	return g, nil
+}
+
+// NewGenerationsRule returns r with the given number of states, from 2 to 256.
+func NewGenerationsRule(r Rule, states int) (GenerationsRule, error) {
+	if states < 2 || states > maxStates {
+		return GenerationsRule{}, fmt.Errorf("NewGenerationsRule: pattern: %w: %v states, expected 2 to %v", ErrRuleString, states, maxStates)
+	}
+	return GenerationsRule{r, states}, nil
+}
+
+// Rule returns the birth/survival part of the rule.
+func (g GenerationsRule) Rule() Rule {
+	return g.rule
+}
+
+// States returns the number of states, counting dead and alive.
+func (g GenerationsRule) States() int {
+	return g.states
+}
+
+// String returns the rule as a rulestring, e.g. "B2/S345/4", leaving out
+// the number of states if it is 2.
+func (g GenerationsRule) String() string {
+	if g.states == 2 {
+		return g.rule.String()
+	}
+	return fmt.Sprintf("%v/%v", g.rule, g.states)
+}
+
+// next is the state after a cell in state s with n live neighbors.
+func (g GenerationsRule) next(s uint8, n int) uint8 {
+	switch {
+	case s == 0:
+		if g.rule.birth.has(n) {
+			return 1
+		}
+		return 0
+	case s == 1 && g.rule.survive.has(n):
+		return 1
+	}
+	// one step closer to dead
+	if int(s)+1 < g.states {
+		return s + 1
+	}
+	return 0
+}
+
+// GenerationsRule returns the rule EvolveGenerations uses, see WithGenerationsRule.
+func (t *Pattern) GenerationsRule() GenerationsRule {
+	return GenerationsRule{t.rule, t.states}
+}
+
+// CheckGrid returns an error wrapping ErrTileSize unless grid is the size
+// of the mask the Pattern was built from, or wrapping ErrBadState if a tile
+// cell is in a state the pattern's GenerationsRule doesn't have.
+func (t *Pattern) CheckGrid(grid Grid) error {
+	return t.checkGrid(grid, t.states)
+}
+
+// EvolveGenerations is Evolve for multi-state grids: it fills in the border
+// of grid with the full state of each tile cell, the same way Evolve does,
+// and writes the next generation of the tile cells to newGrid under the
+// pattern's GenerationsRule. Only cells in state 1 count as live neighbors.
+func (t *Pattern) EvolveGenerations(grid, newGrid Grid) error {
+	if err := t.checkGrids("EvolveGenerations", grid, newGrid, t.states); err != nil {
+		return err
+	}
+
+	g := t.GenerationsRule()
+	t.fillGridBorder(grid.cells)
+	for _, c := range t.cells {
+		n := countState(grid.cells, c.Row, c.Col, t.offsets.at(c.Row), 1)
+		newGrid.cells[c.Row][c.Col] = g.next(grid.cells[c.Row][c.Col], n)
+	}
+	return nil
+}
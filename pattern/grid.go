@@ -0,0 +1,163 @@
+package pattern
+
+import "fmt"
+
+// Grid is a rectangular grid of multi-state cells, the Tile of rules with
+// more than two states such as GenerationsRule. State 0 is dead.
+// Like a slice, a copied Grid shares its cells; use CopyFrom for a real copy.
+type Grid struct {
+	rows, cols int
+	cells      [][]uint8
+}
+
+// NewGrid makes a grid of rows x cols dead cells.
+// Use NewGrid(pat.Rows(), pat.Cols()) for a grid that fits pat.
+// It panics if rows or cols is negative or the grid has more than 1<<26 cells.
+func NewGrid(rows, cols int) Grid {
+	if rows < 0 || cols < 0 || cols > 0 && rows > maxCells/cols {
+		panic(fmt.Sprintf("pattern: NewGrid %vx%v", rows, cols))
+	}
+	cells := make([][]uint8, rows)
+	underlying := make([]uint8, rows*cols)
+	for i := range cells {
+		cells[i], underlying = underlying[:cols], underlying[cols:]
+	}
+	return Grid{rows, cols, cells}
+}
+
+// GridFrom wraps cells without copying them.
+// It returns an error wrapping ErrTileSize if the rows differ in length.
+func GridFrom(cells [][]uint8) (Grid, error) {
+	g := Grid{rows: len(cells), cells: cells}
+	if len(cells) > 0 {
+		g.cols = len(cells[0])
+	}
+	for i, row := range cells {
+		if len(row) != g.cols {
+			return Grid{}, fmt.Errorf("GridFrom: pattern: %w: grid row %v has %v columns, expected %v", ErrTileSize, i, len(row), g.cols)
+		}
+	}
+	return g, nil
+}
+
+// GridOf returns a new grid with state 1 where tile is alive and 0 elsewhere.
+func GridOf(tile Tile) Grid {
+	g := NewGrid(tile.rows, tile.cols)
+	for i, row := range tile.grid {
+		for j, v := range row {
+			if v == alive {
+				g.cells[i][j] = 1
+			}
+		}
+	}
+	return g
+}
+
+// Rows returns the number of rows in the grid.
+func (g Grid) Rows() int {
+	return g.rows
+}
+
+// Cols returns the number of columns in the grid.
+func (g Grid) Cols() int {
+	return g.cols
+}
+
+// Get returns the state at (r, c); positions outside the grid are dead.
+func (g Grid) Get(r, c int) uint8 {
+	if r < 0 || r >= g.rows || c < 0 || c >= g.cols {
+		return 0
+	}
+	return g.cells[r][c]
+}
+
+// Set sets the state at (r, c). It panics, saying why, if (r, c) is outside the grid.
+func (g Grid) Set(r, c int, v uint8) {
+	if r < 0 || r >= g.rows || c < 0 || c >= g.cols {
+		panic(fmt.Sprintf("pattern: Set r:%v c:%v outside the %vx%v grid", r, c, g.rows, g.cols))
+	}
+	g.cells[r][c] = v
+}
+
+// Fill sets every cell of the grid to v.
+func (g Grid) Fill(v uint8) {
+	for _, row := range g.cells {
+		for j := range row {
+			row[j] = v
+		}
+	}
+}
+
+// CopyFrom copies the cells of other into g.
+// It returns an error wrapping ErrTileSize if the sizes differ.
+func (g Grid) CopyFrom(other Grid) error {
+	if g.rows != other.rows || g.cols != other.cols {
+		return fmt.Errorf("CopyFrom: pattern: %w: grid is %vx%v, expected %vx%v", ErrTileSize, other.rows, other.cols, g.rows, g.cols)
+	}
+	for i, row := range other.cells {
+		copy(g.cells[i], row)
+	}
+	return nil
+}
+
+// Cells returns the cells as a [][]uint8. It shares memory with the grid.
+func (g Grid) Cells() [][]uint8 {
+	return g.cells
+}
+
+// checkGrid is CheckGrid for a rule with the given number of states.
+func (t *Pattern) checkGrid(grid Grid, states int) error {
+	if grid.rows != t.rows || grid.cols != t.cols {
+		return fmt.Errorf("%w: grid is %vx%v, expected %vx%v", ErrTileSize, grid.rows, grid.cols, t.rows, t.cols)
+	}
+	for i, c := range t.cells {
+		if s := grid.cells[c.Row][c.Col]; int(s) >= states {
+			return fmt.Errorf("%w: id:%v r:%v c:%v is in state %v, the rule has %v states", ErrBadState, i+1, c.Row, c.Col, s, states)
+		}
+	}
+	return nil
+}
+
+// checkGrids checks grid for a rule with the given number of states, and
+// that newGrid has the same size. Errors start with op.
+func (t *Pattern) checkGrids(op string, grid, newGrid Grid, states int) error {
+	if err := t.checkGrid(grid, states); err != nil {
+		return fmt.Errorf("%v: pattern: %w", op, err)
+	}
+	if err := t.checkGrid(newGrid, maxStates); err != nil {
+		return fmt.Errorf("%v: pattern: newGrid: %w", op, err)
+	}
+	return nil
+}
+
+// fillGridBorder is fillBorder for multi-state grids.
+func (t *Pattern) fillGridBorder(cells [][]uint8) {
+	for _, h := range t.holes {
+		cells[h.Row][h.Col] = 0
+	}
+	for _, u := range t.diag.Uncovered {
+		cells[u.Row][u.Col] = 0
+	}
+
+	for id, v := range t.border {
+		tc := t.cells[id-1]
+		for _, bc := range v {
+			cells[bc.Row][bc.Col] = cells[tc.Row][tc.Col]
+		}
+	}
+}
+
+// countState counts the cells in state s at the given offsets from (row, col).
+func countState(cells [][]uint8, row, col int, offsets []Offset, s uint8) int {
+	n := 0
+	for _, d := range offsets {
+		r, c := row+d.Row, col+d.Col
+		if r < 0 || r >= len(cells) || c < 0 || c >= len(cells[0]) {
+			continue
+		}
+		if cells[r][c] == s {
+			n++
+		}
+	}
+	return n
+}
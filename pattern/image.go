@@ -0,0 +1,154 @@
+package pattern
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// WritePBM writes tile as a binary (P4) PBM, one pixel per cell of the
+// Rows() x Cols() array, with live tile cells black (1).
+func (t *Pattern) WritePBM(w io.Writer, tile [][]bool) error {
+	if err := t.CheckTile(tile); err != nil {
+		return fmt.Errorf("WritePBM: pattern: %w", err)
+	}
+
+	stride := (t.cols + 7) / 8
+	bits := make([]byte, stride*t.rows)
+	t.EachAlive(tile, func(_ int, c Cell) bool {
+		bits[c.Row*stride+c.Col/8] |= 0x80 >> uint(c.Col%8)
+		return true
+	})
+
+	if _, err := fmt.Fprintf(w, "P4\n%d %d\n", t.cols, t.rows); err != nil {
+		return err
+	}
+	_, err := w.Write(bits)
+	return err
+}
+
+// WritePNG writes tile as an 8-bit grayscale PNG, one pixel per cell of the
+// Rows() x Cols() array, with live tile cells black and the rest white.
+func (t *Pattern) WritePNG(w io.Writer, tile [][]bool) error {
+	if err := t.CheckTile(tile); err != nil {
+		return fmt.Errorf("WritePNG: pattern: %w", err)
+	}
+
+	img := image.NewGray(image.Rect(0, 0, t.cols, t.rows))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	t.EachAlive(tile, func(_ int, c Cell) bool {
+		img.SetGray(c.Col, c.Row, color.Gray{0})
+		return true
+	})
+	return png.Encode(w, img)
+}
+
+// MaskFromImage reads an image written by WritePBM or WritePNG back into
+// a grid with one cell per pixel, true where the pixel is black. Any PNG
+// is read, with pixels darker than mid gray counting as black. For an
+// image of a tile the grid is that tile, for one of a mask, e.g. drawn in
+// an editor, it can be given to New. Errors about the image wrap ErrBadImage.
+func MaskFromImage(r io.Reader) ([][]bool, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil {
+		return nil, fmt.Errorf("MaskFromImage: pattern: %w: %v", ErrBadImage, err)
+	}
+	if string(magic) == "P4" {
+		return readPBM(br)
+	}
+
+	img, err := png.Decode(br)
+	if err != nil {
+		return nil, fmt.Errorf("MaskFromImage: pattern: %w: %v", ErrBadImage, err)
+	}
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, fmt.Errorf("MaskFromImage: pattern: %w: empty image", ErrBadImage)
+	}
+	grid := make([][]bool, b.Dy())
+	for y := range grid {
+		grid[y] = make([]bool, b.Dx())
+		for x := range grid[y] {
+			gray := color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray)
+			grid[y][x] = gray.Y < 0x80
+		}
+	}
+	return grid, nil
+}
+
+// readPBM reads a binary (P4) PBM, 1 is black.
+// The size in the header is bounded like any array, and memory is only
+// allocated for the data actually read, so a short file with a large
+// header can't allocate much.
+func readPBM(br *bufio.Reader) ([][]bool, error) {
+	br.Discard(2)
+	var size [2]int
+	for i := range size {
+		n, err := pbmNumber(br)
+		if err != nil {
+			return nil, fmt.Errorf("MaskFromImage: pattern: %w: PBM header: %v", ErrBadImage, err)
+		}
+		size[i] = n
+	}
+	cols, rows := size[0], size[1]
+	if err := checkArea(rows, cols); err != nil {
+		return nil, fmt.Errorf("MaskFromImage: pattern: %w: %v", ErrBadImage, err)
+	}
+	// a single whitespace character ends the header
+	if _, err := br.ReadByte(); err != nil {
+		return nil, fmt.Errorf("MaskFromImage: pattern: %w: PBM header: %v", ErrBadImage, err)
+	}
+
+	var grid [][]bool
+	var buf bytes.Buffer
+	stride := int64((cols + 7) / 8)
+	for y := 0; y < rows; y++ {
+		// the buffer grows with the bytes read, not with the header
+		buf.Reset()
+		if n, err := io.CopyN(&buf, br, stride); err != nil {
+			return nil, fmt.Errorf("MaskFromImage: pattern: %w: PBM row %d of %d has %d of %d bytes", ErrBadImage, y, rows, n, stride)
+		}
+		bits := buf.Bytes()
+		row := make([]bool, cols)
+		for x := range row {
+			row[x] = bits[x/8]&(0x80>>uint(x%8)) != 0
+		}
+		grid = append(grid, row)
+	}
+	return grid, nil
+}
+
+// pbmNumber reads a decimal number from a PBM header, skipping the
+// whitespace and # comments before it.
+func pbmNumber(br *bufio.Reader) (int, error) {
+	n, digits := 0, 0
+	for {
+		c, err := br.ReadByte()
+		switch {
+		case err != nil:
+			return 0, err
+		case c >= '0' && c <= '9':
+			if n > (1<<31-1)/10 {
+				return 0, fmt.Errorf("number too large")
+			}
+			n = n*10 + int(c-'0')
+			digits++
+		case digits > 0:
+			return n, br.UnreadByte()
+		case c == '#':
+			if _, err := br.ReadString('\n'); err != nil {
+				return 0, err
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f':
+		default:
+			return 0, fmt.Errorf("unexpected %q", c)
+		}
+	}
+}
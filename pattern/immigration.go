@@ -0,0 +1,59 @@
+package pattern
+
+import "fmt"
+
+// Species is the state of a cell in the Immigration game, a Grid holds them
+// as uint8. A cell is alive if it is Red or Blue.
+type Species uint8
+
+// The species, with Dead for no cell at all.
+const (
+	Dead Species = iota
+	Red
+	Blue
+)
+
+// String returns the name of the species.
+func (s Species) String() string {
+	switch s {
+	case Dead:
+		return "dead"
+	case Red:
+		return "red"
+	case Blue:
+		return "blue"
+	}
+	return fmt.Sprintf("Species(%d)", uint8(s))
+}
+
+// EvolveImmigration is Evolve for the Immigration game, where two species
+// share the tile. The pattern's Rule decides which cells are alive from
+// the number of live neighbors of either species. Survivors keep their
+// species and a newborn cell takes the species of most of its live
+// neighbors, Red on a tie. Like Evolve it fills in the border of grid
+// first, copying the species of each tile cell.
+func (t *Pattern) EvolveImmigration(grid, newGrid Grid) error {
+	if err := t.checkGrids("EvolveImmigration", grid, newGrid, int(Blue)+1); err != nil {
+		return err
+	}
+
+	t.fillGridBorder(grid.cells)
+	for _, c := range t.cells {
+		red := countState(grid.cells, c.Row, c.Col, t.offsets.at(c.Row), uint8(Red))
+		blue := countState(grid.cells, c.Row, c.Col, t.offsets.at(c.Row), uint8(Blue))
+		s := grid.cells[c.Row][c.Col]
+		next := t.rule.next(s != uint8(Dead), red+blue)
+		switch {
+		case !next:
+			s = uint8(Dead)
+		case s != uint8(Dead):
+			// survives as it is
+		case blue > red:
+			s = uint8(Blue)
+		default:
+			s = uint8(Red)
+		}
+		newGrid.cells[c.Row][c.Col] = s
+	}
+	return nil
+}
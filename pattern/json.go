@@ -0,0 +1,186 @@
+package pattern
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// jsonVersion is the version of the JSON form written by MarshalJSON.
+const jsonVersion = 1
+
+// patternJSON is the JSON form of a Pattern.
+// The mask rows use '1' for tile cells and '0' elsewhere, like the mask CSV.
+type patternJSON struct {
+	Version     int          `json:"version"`
+	Mask        []string     `json:"mask"`
+	Rules       []Offset     `json:"rules"`
+	Contributed []int        `json:"contributed"`
+	Border      []borderJSON `json:"border"`
+	Uncovered   []Cell       `json:"uncovered,omitempty"`
+	Rule        string       `json:"rule,omitempty"`         // missing means Conway
+	Nbhd        string       `json:"neighborhood,omitempty"` // missing means moore
+	Radius      int          `json:"radius,omitempty"`       // missing means 1
+	States      int          `json:"states,omitempty"`       // missing means 2
+}
+
+// borderJSON is a border cell and the id of the cell it copies from.
+type borderJSON struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+	ID  int `json:"id"`
+}
+
+// MarshalJSON saves everything New or NewFinite computed, so a Pattern can be cached
+// and loaded again with UnmarshalJSON without re-running the rules.
+func (t *Pattern) MarshalJSON() ([]byte, error) {
+	p := patternJSON{
+		Version:     jsonVersion,
+		Rules:       t.diag.Rules,
+		Contributed: t.diag.Contributed,
+		Uncovered:   t.diag.Uncovered,
+		Rule:        t.rule.String(),
+		Nbhd:        t.nbhd.String(),
+		Radius:      t.radius,
+		States:      t.states,
+	}
+	for _, row := range t.mask {
+		b := make([]byte, len(row))
+		for j, id := range row {
+			b[j] = '0'
+			if id != 0 {
+				b[j] = '1'
+			}
+		}
+		p.Mask = append(p.Mask, string(b))
+	}
+
+	ids := make([]int, 0, len(t.border))
+	for id := range t.border {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		for _, bc := range t.border[id] {
+			p.Border = append(p.Border, borderJSON{bc.Row, bc.Col, id})
+		}
+	}
+
+	return json.Marshal(p)
+}
+
+// UnmarshalJSON loads a Pattern saved by MarshalJSON.
+// The rules are not applied again, but the data is checked the way New checks
+// its arguments: the mask, rules, rulestring, neighborhood and radius must
+// be valid, each border cell must be a dead neighbor of the tile copied from
+// an existing cell, and the cells left uncovered must be exactly the ones
+// saved.
+// Errors about the data wrap ErrCorrupt, and t is only changed on success.
+func (t *Pattern) UnmarshalJSON(data []byte) error {
+	var p patternJSON
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	if p.Version != jsonVersion {
+		return fmt.Errorf("UnmarshalJSON: pattern: %w: version %v, expected %v", ErrCorrupt, p.Version, jsonVersion)
+	}
+
+	mask := make([][]bool, len(p.Mask))
+	for i, row := range p.Mask {
+		mask[i] = make([]bool, len(row))
+		for j := 0; j < len(row); j++ {
+			switch row[j] {
+			case '0':
+			case '1':
+				mask[i][j] = alive
+			default:
+				return fmt.Errorf("UnmarshalJSON: pattern: %w: mask r:%v c:%v is %q", ErrCorrupt, i, j, row[j])
+			}
+		}
+	}
+	n := &Pattern{}
+	rule, nbhd, radius := Conway, Moore, 1
+	if p.Rule != "" {
+		r, err := ParseRule(p.Rule)
+		if err != nil {
+			return fmt.Errorf("UnmarshalJSON: pattern: %w: %v", ErrCorrupt, err)
+		}
+		rule = r
+	}
+	if p.Nbhd != "" {
+		nb, err := ParseNeighborhood(p.Nbhd)
+		if err != nil {
+			return fmt.Errorf("UnmarshalJSON: pattern: %w: %v", ErrCorrupt, err)
+		}
+		nbhd = nb
+	}
+	if p.Radius != 0 {
+		radius = p.Radius
+	}
+	if err := n.setRule(rule, nbhd, radius); err != nil {
+		return fmt.Errorf("UnmarshalJSON: pattern: %w: %v", ErrCorrupt, err)
+	}
+	if p.States != 0 {
+		if p.States < 2 || p.States > maxStates {
+			return fmt.Errorf("UnmarshalJSON: pattern: %w: %v states, expected 2 to %v", ErrCorrupt, p.States, maxStates)
+		}
+		n.states = p.States
+	}
+
+	if err := checkMask(mask, n.radius); err != nil {
+		return fmt.Errorf("UnmarshalJSON: pattern: %w", err)
+	}
+	n.rows, n.cols = len(mask), len(mask[0])
+	// a pattern from NewFinite has no rules
+	if len(p.Rules) > 0 {
+		if err := checkRules(p.Rules, n.rows, n.cols); err != nil {
+			return fmt.Errorf("UnmarshalJSON: pattern: %w", err)
+		}
+	}
+	if err := n.nbhd.checkRows(p.Rules); err != nil {
+		return fmt.Errorf("UnmarshalJSON: pattern: %w", err)
+	}
+	if len(p.Contributed) != len(p.Rules) {
+		return fmt.Errorf("UnmarshalJSON: pattern: %w: %v contributed counts for %v rules", ErrCorrupt, len(p.Contributed), len(p.Rules))
+	}
+	n.setMask(mask)
+	if len(n.cells) == 0 {
+		return fmt.Errorf("UnmarshalJSON: pattern: %w: no cells in the tile", ErrEmptyMask)
+	}
+
+	claims := make(map[Cell]claim, len(p.Border))
+	n.border = make(map[int][]Cell)
+	for _, b := range p.Border {
+		bc := Cell{b.Row, b.Col}
+		switch {
+		case b.ID < 1 || b.ID > len(n.cells):
+			return fmt.Errorf("UnmarshalJSON: pattern: %w: border r:%v c:%v copies unknown id:%v", ErrCorrupt, b.Row, b.Col, b.ID)
+		case b.Row < 0 || b.Row >= n.rows || b.Col < 0 || b.Col >= n.cols:
+			return fmt.Errorf("UnmarshalJSON: pattern: %w: border r:%v c:%v is outside the mask", ErrCorrupt, b.Row, b.Col)
+		case mask[b.Row][b.Col] || n.countNeighbors(mask, b.Row, b.Col) == 0:
+			return fmt.Errorf("UnmarshalJSON: pattern: %w: r:%v c:%v is not on the border", ErrCorrupt, b.Row, b.Col)
+		}
+		if _, ok := claims[bc]; ok {
+			return fmt.Errorf("UnmarshalJSON: pattern: %w: border r:%v c:%v is repeated", ErrCorrupt, b.Row, b.Col)
+		}
+		claims[bc] = claim{id: b.ID}
+		n.border[b.ID] = append(n.border[b.ID], bc)
+	}
+
+	n.diag.Rules = p.Rules
+	n.diag.Contributed = p.Contributed
+	if err := n.finish(mask, claims, false); err != nil {
+		return err
+	}
+	if len(n.diag.Uncovered) != len(p.Uncovered) {
+		return fmt.Errorf("UnmarshalJSON: pattern: %w: %v cells uncovered, expected %v", ErrCorrupt, len(n.diag.Uncovered), len(p.Uncovered))
+	}
+	for i, c := range n.diag.Uncovered {
+		if c != p.Uncovered[i] {
+			return fmt.Errorf("UnmarshalJSON: pattern: %w: r:%v c:%v is uncovered", ErrCorrupt, c.Row, c.Col)
+		}
+	}
+
+	*t = *n
+	return nil
+}
@@ -0,0 +1,116 @@
+package pattern
+
+import "fmt"
+
+// Neighborhood says which cells around a cell count as its neighbors,
+// both when Evolve counts live neighbors and when New decides which dead
+// cells around the tile are on the border. WithRadius makes it larger.
+type Neighborhood int
+
+// maxRadius is the largest radius of a neighborhood, see WithRadius.
+const maxRadius = 7
+
+const (
+	// Moore is the 8 cells around a cell, diagonals included. It is the default.
+	// With radius r it is the (2r+1)x(2r+1) square around the cell.
+	Moore Neighborhood = iota
+
+	// VonNeumann is the 4 cells above, below, left and right of a cell.
+	// With radius r it is the cells at most r steps away along rows and columns.
+	VonNeumann
+
+	// Hex is the 6 cells around a cell in a grid of hexagons, laid out in
+	// rows with every odd row shifted half a cell to the right. A cell in an
+	// even row has the neighbors left and right of it and, above and below
+	// it, the ones in columns c-1 and c; in an odd row columns c and c+1.
+	// Rows are numbered in the mask, and the rules must move the tile an
+	// even number of rows so that the copies keep the shift.
+	// With radius r it is the cells at most r steps away across hexagons.
+	Hex
+)
+
+// String returns the name ParseNeighborhood accepts.
+func (n Neighborhood) String() string {
+	switch n {
+	case Moore:
+		return "moore"
+	case VonNeumann:
+		return "vonneumann"
+	case Hex:
+		return "hex"
+	}
+	return fmt.Sprintf("Neighborhood(%d)", int(n))
+}
+
+// ParseNeighborhood returns the Neighborhood named s: moore, vonneumann or hex.
+func ParseNeighborhood(s string) (Neighborhood, error) {
+	for _, n := range []Neighborhood{Moore, VonNeumann, Hex} {
+		if s == n.String() {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("ParseNeighborhood: pattern: %w: %q, expected moore, vonneumann or hex", ErrBadNeighborhood, s)
+}
+
+// Size returns the number of neighbors of a cell in the neighborhood
+// with the given radius, e.g. 8 for Moore and 6 for Hex with radius 1.
+func (n Neighborhood) Size(radius int) int {
+	return len(n.offsets(radius)[0])
+}
+
+// offsetTable lists the offsets from a cell to its neighbors, for a cell
+// in an even row and for one in an odd row. Only Hex tells them apart.
+type offsetTable [2][]Offset
+
+// at returns the offsets for a cell in row.
+func (o offsetTable) at(row int) []Offset {
+	return o[row&1]
+}
+
+// offsets returns the offsets from a cell to its neighbors within radius
+// in row-major order, with nil entries if n is not a known Neighborhood.
+func (n Neighborhood) offsets(radius int) offsetTable {
+	var o offsetTable
+	if n != Moore && n != VonNeumann && n != Hex {
+		return o
+	}
+	for parity := range o {
+		for r := -radius; r <= radius; r++ {
+			for c := -radius; c <= radius; c++ {
+				switch {
+				case r == 0 && c == 0:
+					continue
+				case n == VonNeumann && abs(r)+abs(c) > radius:
+					continue
+				case n == Hex && hexDistance(parity, 0, parity+r, c) > radius:
+					continue
+				}
+				o[parity] = append(o[parity], Offset{r, c})
+			}
+		}
+	}
+	return o
+}
+
+// checkRows checks that the rules keep the rows of n lined up: in Hex, a
+// rule that moves the tile an odd number of rows would put its even rows
+// where the odd ones are shifted.
+func (n Neighborhood) checkRows(rules []Offset) error {
+	if n != Hex {
+		return nil
+	}
+	for _, rule := range rules {
+		if rule.Row%2 != 0 {
+			return fmt.Errorf("%w: %v moves the tile an odd number of rows, which breaks the rows of the %v neighborhood", ErrBadRule, rule, n)
+		}
+	}
+	return nil
+}
+
+// hexDistance returns the number of steps between two cells of the Hex layout.
+func hexDistance(r1, c1, r2, c2 int) int {
+	// in axial coordinates q, r the third coordinate is -q-r
+	q1, q2 := c1-(r1-(r1&1))/2, c2-(r2-(r2&1))/2
+	dq, dr := q2-q1, r2-r1
+	return (abs(dq) + abs(dr) + abs(dq+dr)) / 2
+}
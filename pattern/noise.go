@@ -0,0 +1,48 @@
+package pattern
+
+import "math/rand"
+
+// Probabilities makes a rule noisy: when the rule says a dead cell comes
+// alive, it does so with probability Birth, and when it says a live cell
+// dies, it does so with probability Death. Otherwise the cell keeps its
+// state. Cells the rule leaves alone are never touched.
+type Probabilities struct {
+	Birth, Death float64
+}
+
+// Certain is the probability 1 for both, a rule without noise.
+var Certain = Probabilities{Birth: 1, Death: 1}
+
+// WithNoise makes every Step apply the pattern's rule with the
+// probabilities p, drawing from rng in id order so that a seeded rng
+// always gives the same generations. rng is only used, and only needs to
+// be non-nil, when a probability is below 1. The Pattern itself is not
+// changed, so Evolve and everything else outside the Simulation still
+// follow the rule exactly.
+func WithNoise(p Probabilities, rng *rand.Rand) SimulationOption {
+	return func(s *Simulation) {
+		s.noise = &noise{p, rng}
+	}
+}
+
+// noise applies Probabilities to the rule, drawing from rng, see WithNoise.
+type noise struct {
+	p   Probabilities
+	rng *rand.Rand
+}
+
+// apply returns the next state of a cell in state s that the rule makes
+// next: next if the change happens, s if the noise stops it.
+func (n *noise) apply(s, next bool) bool {
+	if next == s {
+		return next
+	}
+	p := n.p.Death
+	if s == dead {
+		p = n.p.Birth
+	}
+	if n.rng.Float64() < p {
+		return next
+	}
+	return s
+}
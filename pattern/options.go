@@ -0,0 +1,90 @@
+package pattern
+
+// Option changes how New builds a Pattern.
+// The zero set of options gives the behavior New has always had.
+type Option func(*options)
+
+// options holds the settings collected from the Options passed to New.
+type options struct {
+	// strictCoverage makes New fail when a neighbor of the tile is left uncovered.
+	strictCoverage bool
+
+	// symmetricRules adds the negation of every rule.
+	symmetricRules bool
+
+	// rule decides the next state of a cell, and states is the number of
+	// states of a GenerationsRule.
+	rule   Rule
+	states int
+
+	// neighborhood and radius say which cells are neighbors.
+	neighborhood Neighborhood
+	radius       int
+}
+
+// defaultOptions returns the settings used when New is given no Options.
+func defaultOptions() options {
+	return options{
+		strictCoverage: true,
+		rule:           Conway,
+		states:         2,
+		neighborhood:   Moore,
+		radius:         1,
+	}
+}
+
+// WithStrictCoverage sets whether New returns a CoverageError when the rules
+// leave a neighbor of the tile uncovered (the default).
+// With strict set to false New accepts the pattern, the uncovered cells are
+// treated as dead, and they are listed in Diagnostics().Uncovered.
+func WithStrictCoverage(strict bool) Option {
+	return func(o *options) {
+		o.strictCoverage = strict
+	}
+}
+
+// WithSymmetricRules sets whether New adds the negation of every rule that
+// is missing it, so only one of each pair +v, -v needs to be given.
+// The default is false. Diagnostics().Rules lists the rules actually used.
+func WithSymmetricRules(sym bool) Option {
+	return func(o *options) {
+		o.symmetricRules = sym
+	}
+}
+
+// WithRule makes Evolve use r instead of Conway's rule, e.g. a rule from
+// ParseRule("B36/S23") for HighLife or a table from LoadRuleTable.
+func WithRule(r Rule) Option {
+	return func(o *options) {
+		o.rule = r
+	}
+}
+
+// WithGenerationsRule makes EvolveGenerations use g. Evolve, on two-state
+// tiles, uses its birth/survival part as if given WithRule(g.Rule()).
+func WithGenerationsRule(g GenerationsRule) Option {
+	return func(o *options) {
+		o.rule, o.states = g.rule, g.states
+	}
+}
+
+// WithNeighborhood makes the pattern count neighbors in n instead of the
+// Moore neighborhood, e.g. VonNeumann to count only orthogonal neighbors.
+// The border then only holds the cells that are neighbors of the tile in n,
+// and the rule must not count more neighbors than n has.
+func WithNeighborhood(n Neighborhood) Option {
+	return func(o *options) {
+		o.neighborhood = n
+	}
+}
+
+// WithRadius makes the neighborhood reach r cells away instead of 1, for
+// Larger than Life style rules: with Moore every cell in the (2r+1)x(2r+1)
+// square around a cell is a neighbor. The border is then r cells thick,
+// tile cells must be at least r cells from the edge of the mask, and the
+// rules must cover everything within r of the tile. r goes up to 7.
+func WithRadius(r int) Option {
+	return func(o *options) {
+		o.radius = r
+	}
+}
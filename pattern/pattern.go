@@ -2,7 +2,11 @@
 package pattern
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
 )
 
 // Cell conveniently wraps a 2D index (row, col)
@@ -14,6 +18,16 @@ type Cell struct {
 type Offset Cell
 
 // Pattern represents a 2D pattern for Conway's Game of Life as a tessellation
+//
+// A Pattern is never modified after New returns and every method only reads
+// it, so it is safe for concurrent use by multiple goroutines, e.g. to evolve
+// many tiles at once. Each goroutine must use its own tile arrays.
+// No Option makes a Pattern stateful: the random number generators of
+// WithNoise and WithMutation belong to a Simulation, which is not safe for
+// concurrent use. The exceptions are UnmarshalJSON, which replaces the
+// whole Pattern and must not run while it is in use, and the deprecated
+// Cells and Border fields, which callers can write to.
+// Use Clone for a copy that shares nothing, e.g. to change the deprecated fields.
 type Pattern struct {
 	// rows and cols are dimensions of rectangular array containing tile.
 	rows, cols int
@@ -23,15 +37,61 @@ type Pattern struct {
 	// This can be used to determine whether a cell is in the tile or not.
 	mask [][]int
 
-	// Cells is an map of cell coordinates indexed by cell id.
+	// cells holds the coordinates of the cells that are part of the tile.
+	// cells[id-1] is the cell with the given id; ids start at 1.
+	// Note: cells is reverse index to mask.
+	cells []Cell
+
+	// border is a map indexed by cell id to a slice of cell coordinates.
+	// These coordinates are used to fill in the border around a tile.
+	// This makes it possible to simulate the tessellation correctly!
+	border map[int][]Cell
+
+	// canon holds, for each position in the array, the id of the cell
+	// whose value is there: the cell itself or the source of a border cell.
+	// A value of zero means neither, see Canonicalize.
+	canon [][]int
+
+	// neighbors[id-1] lists the canonical ids of the neighbors of cell id.
+	neighbors [][]int
+
+	// components groups the cell ids into connected pieces of the tile.
+	components [][]int
+
+	// holes are dead cells enclosed by the tile that no copy covers
+	// and no piece of the tile would fit in.
+	// They are always dead, see Holes.
+	holes []Cell
+
+	// diag records how the border was built, see Diagnostics.
+	diag Diagnostics
+
+	// rule decides the next state of a cell, see WithRule, and states is the
+	// number of states EvolveGenerations uses, see WithGenerationsRule.
+	rule   Rule
+	states int
+
+	// nbhd and radius say which cells are neighbors and offsets lists them,
+	// see WithNeighborhood and WithRadius.
+	nbhd    Neighborhood
+	radius  int
+	offsets offsetTable
+
+	// Cells is a map of cell coordinates indexed by cell id.
 	// These coordinates correspond the the cells that are part of the tile.
 	// Cells that are in the array but are not part of the tile are excluded.
-	// Note: Cells is reverse index to mask.
+	//
+	// Deprecated: Cells is a copy made by New for existing callers and is
+	// never read by the package, so changing it does not affect Evolve.
+	// Use EachCell, NumCells or CellByID instead.
 	Cells map[int]Cell
 
-	// Border is a map indexed by cell id to a slice of cell coordinates.
-	// These coordinates are used to fill in the Border around a tile.
-	// This makes it possible to simulate the tessellation correctly!
+	// Border is a map indexed by cell id to a slice of border coordinates
+	// that take their value from that cell.
+	//
+	// Deprecated: Border is a copy made by New for existing callers and is
+	// never read by the package, so changing it does not affect Evolve.
+	// Use BorderOf instead.
 	Border map[int][]Cell
 }
 
@@ -40,22 +100,276 @@ const (
 	dead  = false
 )
 
+// claim records which rule and id first filled a border cell.
+type claim struct {
+	rule Offset
+	id   int
+}
+
+// maxOffsetScale bounds rule offsets to this many times the mask dimensions.
+const maxOffsetScale = 4
+
+// maxCells bounds the rows x cols of the arrays the package allocates, so
+// that a bad size is an error instead of running out of memory.
+const maxCells = 1 << 26
+
+// checkArea checks that a rows x cols array can be allocated.
+// Both must be above 0; the product is checked without overflowing.
+func checkArea(rows, cols int) error {
+	if rows <= 0 || cols <= 0 {
+		return fmt.Errorf("%w: %vx%v array", ErrEmptyMask, rows, cols)
+	}
+	if cols > maxCells/rows {
+		return fmt.Errorf("%w: %vx%v array has more than %v cells", ErrTooLarge, rows, cols, maxCells)
+	}
+	return nil
+}
+
+// abs is the absolute value of x.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
 // New makes a tile based on a tile mask and rules for tesselating.
-// The mask says which cells are in the tile. Must be rectangular. All cells on edge must be false.
+// The mask says which cells are in the tile. Must be rectangular, with at least one cell in the tile,
+// and have at most 1<<26 cells.
+// All cells on edge must be false.
 // The rules say how to slide copies of the tile so the original is completely surrounded.
-func New(mask [][]bool, rules []Offset) (*Pattern, error) {
+// New returns an error if any neighbor of a tile cell is left uncovered.
+// Options may change these defaults, see Option.
+func New(mask [][]bool, rules []Offset, opts ...Option) (*Pattern, error) {
+
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	t, err := newPattern("New", mask, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkRules(rules, t.rows, t.cols); err != nil {
+		return nil, fmt.Errorf("New: pattern: %w", err)
+	}
+	if err := t.nbhd.checkRows(rules); err != nil {
+		return nil, fmt.Errorf("New: pattern: %w", err)
+	}
+
+	// for a tessellation every -v is needed as well as +v
+	if o.symmetricRules {
+		seen := make(map[Offset]bool, 2*len(rules))
+		for _, rule := range rules {
+			seen[rule] = true
+		}
+		rules = append([]Offset(nil), rules...)
+		for _, rule := range rules {
+			neg := Offset{-rule.Row, -rule.Col}
+			if !seen[neg] {
+				seen[neg] = true
+				rules = append(rules, neg)
+			}
+		}
+	}
+
+	t.setMask(mask)
 
+	if len(t.cells) == 0 {
+		return nil, fmt.Errorf("New: pattern: %w: no cells in the tile", ErrEmptyMask)
+	}
+
+	// Calculate border by tessellating
+
+	claims := make(map[Cell]claim)
+
+	// Apply rules. Each rule "creates" a new copy of the tile.
+	t.border = make(map[int][]Cell)
+	t.diag.Rules = append([]Offset(nil), rules...)
+	t.diag.Contributed = make([]int, len(rules))
+	for k, rule := range rules {
+		for i, c := range t.cells {
+			id := i + 1
+			row := c.Row + rule.Row
+			col := c.Col + rule.Col
+
+			// check if offset cell is in range
+			if (0 <= row && row < t.rows) && (0 <= col && col < t.cols) {
+				// we assumed that the rules correctly tesselate the plane
+				// here we just double check that the tiled copy is not causing overlap
+				if mask[row][col] {
+					return nil, &OverlapError{Rule: rule, Row: row, Col: col, ID: id}
+				}
+				// check that the cell is neighbor to tile (and hence on border)
+				if t.countNeighbors(mask, row, col) > 0 {
+					// two copies may not put different cells in the same place
+					bc := Cell{row, col}
+					t.diag.Contributed[k]++
+					if prev, ok := claims[bc]; ok {
+						if prev.id != id {
+							return nil, &ConflictError{Cell: bc, Rules: [2]Offset{prev.rule, rule}, IDs: [2]int{prev.id, id}}
+						}
+						continue // same source cell, nothing to add
+					}
+					claims[bc] = claim{rule, id}
+					t.border[id] = append(t.border[id], bc)
+				}
+			}
+		}
+	}
+
+	// a rule that places nothing on the border is a typo or in the wrong units
+	var unused []string
+	for k, n := range t.diag.Contributed {
+		if n == 0 {
+			unused = append(unused, fmt.Sprint(rules[k]))
+		}
+	}
+	if len(unused) > 0 {
+		return nil, fmt.Errorf("New: pattern: %w: %v place no cells on the border", ErrBadRule, strings.Join(unused, " "))
+	}
+
+	if err := t.finish(mask, claims, o.strictCoverage); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewFinite makes a tile that is not tessellated: there are no rules and
+// no border, so every neighbor outside the tile is dead, as if the tile
+// were an island. The mask is checked as in New, and the cells and their
+// ids are the same New would give. Diagnostics().Uncovered lists the dead
+// neighbors. The options WithStrictCoverage and WithSymmetricRules have
+// no effect.
+func NewFinite(mask [][]bool, opts ...Option) (*Pattern, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	t, err := newPattern("NewFinite", mask, o)
+	if err != nil {
+		return nil, err
+	}
+	t.setMask(mask)
+	if len(t.cells) == 0 {
+		return nil, fmt.Errorf("NewFinite: pattern: %w: no cells in the tile", ErrEmptyMask)
+	}
+
+	t.border = make(map[int][]Cell)
+	if err := t.finish(mask, nil, false); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// newPattern starts a Pattern for New or NewFinite (op) by checking the
+// options and the mask.
+func newPattern(op string, mask [][]bool, o options) (*Pattern, error) {
 	t := &Pattern{}
+	if err := t.setRule(o.rule, o.neighborhood, o.radius); err != nil {
+		return nil, fmt.Errorf("%s: pattern: %w", op, err)
+	}
+	if o.states < 2 || o.states > maxStates {
+		return nil, fmt.Errorf("%s: pattern: %w: %v states, expected 2 to %v", op, ErrRuleString, o.states, maxStates)
+	}
+	t.states = o.states
 
+	if err := checkMask(mask, t.radius); err != nil {
+		return nil, fmt.Errorf("%s: pattern: %w", op, err)
+	}
 	t.rows = len(mask)
 	t.cols = len(mask[0])
+	return t, nil
+}
 
-	for _, row := range mask {
-		if len(row) != t.cols {
-			return nil, fmt.Errorf("New: pattern: mask is not rectangular")
+// setRule sets the rule, neighborhood and radius after checking that they fit together.
+func (t *Pattern) setRule(r Rule, n Neighborhood, radius int) error {
+	if radius < 1 || radius > maxRadius {
+		return fmt.Errorf("%w: radius %v, expected 1 to %v", ErrBadNeighborhood, radius, maxRadius)
+	}
+	offsets := n.offsets(radius)
+	if offsets[0] == nil {
+		return fmt.Errorf("%w: %v", ErrBadNeighborhood, n)
+	}
+	if r.max() > len(offsets[0]) {
+		return fmt.Errorf("%w: %v counts %v neighbors, the %v neighborhood of radius %v has %v",
+			ErrRuleString, r, r.max(), n, radius, len(offsets[0]))
+	}
+	t.rule, t.nbhd, t.radius, t.offsets = r, n, radius, offsets
+	t.states = 2
+	return nil
+}
+
+// checkMask checks that mask is a non-empty rectangle with no tile cells
+// within radius of the edge.
+func checkMask(mask [][]bool, radius int) error {
+	if len(mask) == 0 || len(mask[0]) == 0 {
+		return ErrEmptyMask
+	}
+
+	rows, cols := len(mask), len(mask[0])
+	for i, row := range mask {
+		if len(row) != cols {
+			return fmt.Errorf("%w: row %v has %v columns, expected %v", ErrRaggedMask, i, len(row), cols)
+		}
+	}
+	// the tiles of the pattern are as large as the mask
+	if err := checkArea(rows, cols); err != nil {
+		return err
+	}
+
+	// cells on the edge have neighbors outside the array, so they can't be
+	// in the tile; list all of them so the mask can be fixed in one pass
+	var onEdge []Cell
+	for i, row := range mask {
+		for j, cell := range row {
+			edge := i < radius || i >= rows-radius || j < radius || j >= cols-radius
+			if edge && cell == alive {
+				onEdge = append(onEdge, Cell{i, j})
+			}
+		}
+	}
+	if len(onEdge) > 0 {
+		return &MaskEdgeError{Cells: onEdge, Radius: radius}
+	}
+	return nil
+}
+
+// checkRules checks the rules for a rows x cols mask.
+func checkRules(rules []Offset, rows, cols int) error {
+	// an empty, zero or repeated rule is always a mistake by the caller
+	if len(rules) == 0 {
+		return fmt.Errorf("%w: no rules", ErrBadRule)
+	}
+	seen := make(map[Offset]bool, len(rules))
+	for _, rule := range rules {
+		if rule == (Offset{}) {
+			return fmt.Errorf("%w: %v does not move the tile", ErrBadRule, rule)
 		}
+		if seen[rule] {
+			return fmt.Errorf("%w: %v is repeated", ErrBadRule, rule)
+		}
+		seen[rule] = true
 	}
 
+	// offsets far larger than the array can't border the tile, and
+	// rejecting them keeps the coordinate arithmetic far from overflow;
+	// the offsets are not negated, as abs(math.MinInt) is still negative
+	for _, rule := range rules {
+		if rule.Row < -maxOffsetScale*rows || rule.Row > maxOffsetScale*rows ||
+			rule.Col < -maxOffsetScale*cols || rule.Col > maxOffsetScale*cols {
+			return fmt.Errorf("%w: %v is more than %v times the %vx%v mask away",
+				ErrBadRule, rule, maxOffsetScale, rows, cols)
+		}
+	}
+	return nil
+}
+
+// setMask assigns each cell of the checked mask an id, filling t.mask and t.cells.
+func (t *Pattern) setMask(mask [][]bool) {
 	// allocate t.mask
 	t.mask = make([][]int, t.rows)
 	underlying := make([]int, t.rows*t.cols)
@@ -63,47 +377,231 @@ func New(mask [][]bool, rules []Offset) (*Pattern, error) {
 		t.mask[i], underlying = underlying[:t.cols], underlying[t.cols:]
 	}
 
-	// allocate t.Cells
-	t.Cells = make(map[int]Cell)
-
 	// Assign each cell in the tile an id.
-	// also fill t.Cells
+	// also fill t.cells
 	id := 0
 	for i, row := range mask {
 		for j, cell := range row {
 			if cell == alive {
 				id++
 				t.mask[i][j] = id
-				t.Cells[id] = Cell{i, j}
+				t.cells = append(t.cells, Cell{i, j})
 			}
 		}
 	}
+}
 
-	// Calculate Border by tessellating
+// finish works out everything that follows from the mask and the border
+// in claims, once t.border is built. With strict set it returns a
+// CoverageError if any neighbor of the tile is left uncovered.
+func (t *Pattern) finish(mask [][]bool, claims map[Cell]claim, strict bool) error {
+	t.components = t.findComponents()
+	pieces := make([][]Cell, len(t.components))
+	for i, piece := range t.components {
+		for _, id := range piece {
+			pieces[i] = append(pieces[i], t.cells[id-1])
+		}
+	}
+	t.holes = findHoles(mask, claims, pieces)
+	isHole := make(map[Cell]bool, len(t.holes))
+	for _, h := range t.holes {
+		isHole[h] = true
+	}
 
-	// Apply rules. Each rule "creates" a new copy of the tile.
-	t.Border = make(map[int][]Cell)
-	for _, rule := range rules {
-		for id, c := range t.Cells {
-			row := c.Row + rule.Row
-			col := c.Col + rule.Col
+	// every neighbor of a tile cell must be in the tile or on the border,
+	// otherwise it silently counts as dead forever (holes are meant to).
+	// For a tile in several pieces this also checks the gaps between them,
+	// including enclosed ones a piece would fit in, see findHoles.
+	var uncovered []Cell
+	affected := make(map[Cell][]int)
+	for i, c := range t.cells {
+		for _, d := range t.offsets.at(c.Row) {
+			n := Cell{c.Row + d.Row, c.Col + d.Col}
+			if _, ok := claims[n]; ok || mask[n.Row][n.Col] || isHole[n] {
+				continue
+			}
+			if affected[n] == nil {
+				uncovered = append(uncovered, n)
+			}
+			affected[n] = append(affected[n], i+1)
+		}
+	}
+	if len(uncovered) > 0 && strict {
+		return &CoverageError{Uncovered: uncovered, Affected: affected, Radius: t.radius}
+	}
+	t.diag.Uncovered = uncovered
 
-			// check if offset cell is in range
-			if (0 <= row && row < t.rows) && (0 <= col && col < t.cols) {
-				// we assumed that the rules correctly tesselate the plane
-				// here we just double check that the tiled copy is not causing overlap
-				if mask[row][col] {
-					return nil, fmt.Errorf("rule %v caused overlap r:%v c:%v, id:%v", rule, row, col, id)
-				}
-				// check that the cell is neighbor to tile (and hence on border)
-				if countNeighbors(mask, row, col) > 0 {
-					t.Border[id] = append(t.Border[id], Cell{row, col})
-				}
+	t.canon = make([][]int, t.rows)
+	underlying := make([]int, t.rows*t.cols)
+	for i := range t.canon {
+		t.canon[i], underlying = underlying[:t.cols], underlying[t.cols:]
+		copy(t.canon[i], t.mask[i])
+	}
+	for id, v := range t.border {
+		for _, bc := range v {
+			t.canon[bc.Row][bc.Col] = id
+		}
+	}
+
+	t.neighbors = make([][]int, len(t.cells))
+	for i, c := range t.cells {
+		for _, d := range t.offsets.at(c.Row) {
+			if n := t.canon[c.Row+d.Row][c.Col+d.Col]; n != 0 {
+				t.neighbors[i] = append(t.neighbors[i], n)
 			}
 		}
 	}
 
-	return t, nil
+	// exported copies for callers of the deprecated fields
+	t.Cells = make(map[int]Cell, len(t.cells))
+	for i, c := range t.cells {
+		t.Cells[i+1] = c
+	}
+	t.Border = make(map[int][]Cell, len(t.border))
+	for id, v := range t.border {
+		t.Border[id] = append([]Cell(nil), v...)
+	}
+
+	return nil
+}
+
+// NewFromCells is like New but takes the tile as a list of cells in a
+// rows x cols array instead of a dense mask.
+// Every cell must be inside the array and listed only once, and an array
+// of more than 1<<26 cells is an error wrapping ErrTooLarge.
+func NewFromCells(cells []Cell, rows, cols int, rules []Offset, opts ...Option) (*Pattern, error) {
+	if err := checkArea(rows, cols); err != nil {
+		return nil, fmt.Errorf("NewFromCells: pattern: %w", err)
+	}
+
+	mask := make([][]bool, rows)
+	underlying := make([]bool, rows*cols)
+	for i := range mask {
+		mask[i], underlying = underlying[:cols], underlying[cols:]
+	}
+
+	for _, c := range cells {
+		if c.Row < 0 || c.Row >= rows || c.Col < 0 || c.Col >= cols {
+			return nil, fmt.Errorf("NewFromCells: pattern: %w: %v is outside the %vx%v array", ErrBadCell, c, rows, cols)
+		}
+		if mask[c.Row][c.Col] {
+			return nil, fmt.Errorf("NewFromCells: pattern: %w: %v is repeated", ErrBadCell, c)
+		}
+		mask[c.Row][c.Col] = alive
+	}
+
+	return New(mask, rules, opts...)
+}
+
+// NewLattice is like New but makes the rules from the two basis vectors u
+// and v of the lattice the tile repeats on: every a*u + b*v for a and b
+// in -1, 0, 1 except 0, 0, or from -r to r with WithRadius(r), since a
+// thicker border can need copies further away. Combinations that can't
+// reach the border of this tile are left out. u and v must not be parallel,
+// and are bounded like the rules of New, to 4 times the mask dimensions.
+func NewLattice(mask [][]bool, u, v Offset, opts ...Option) (*Pattern, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// check the mask and the radius before they size anything
+	t, err := newPattern("NewLattice", mask, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// like checkRules, bound the basis vectors so the products below can't overflow
+	for _, w := range []Offset{u, v} {
+		if w.Row < -maxOffsetScale*t.rows || w.Row > maxOffsetScale*t.rows ||
+			w.Col < -maxOffsetScale*t.cols || w.Col > maxOffsetScale*t.cols {
+			return nil, fmt.Errorf("NewLattice: pattern: %w: basis vector %v is more than %v times the %vx%v mask away",
+				ErrBadRule, w, maxOffsetScale, t.rows, t.cols)
+		}
+	}
+	if u.Row*v.Col-u.Col*v.Row == 0 {
+		return nil, fmt.Errorf("NewLattice: pattern: %w: basis vectors %v and %v are parallel", ErrBadRule, u, v)
+	}
+
+	var rules []Offset
+	for a := -t.radius; a <= t.radius; a++ {
+		for b := -t.radius; b <= t.radius; b++ {
+			rule := Offset{a*u.Row + b*v.Row, a*u.Col + b*v.Col}
+			if rule != (Offset{}) && reaches(mask, rule, t.offsets) {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	return New(mask, rules, opts...)
+}
+
+// reaches reports whether rule moves any cell of mask onto the tile or next
+// to it, with offsets leading to the neighbors of a cell.
+// Rules that don't are skipped by NewLattice. The mask must be rectangular.
+func reaches(mask [][]bool, rule Offset, offsets offsetTable) bool {
+	for i, row := range mask {
+		for j, cell := range row {
+			if cell != alive {
+				continue
+			}
+			r, c := i+rule.Row, j+rule.Col
+			if r < 0 || r >= len(mask) || c < 0 || c >= len(row) {
+				continue
+			}
+			if mask[r][c] || countNeighbors(mask, r, c, offsets.at(r)) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Clone returns a deep copy of t that shares no memory with it.
+// A shared Pattern is already safe to use from many goroutines, as long as
+// nobody writes to the deprecated Cells and Border fields; Clone gives each
+// goroutine its own copy of those too.
+func (t *Pattern) Clone() *Pattern {
+	c := &Pattern{
+		rows:       t.rows,
+		cols:       t.cols,
+		mask:       copyIDs(t.mask),
+		cells:      append([]Cell(nil), t.cells...),
+		border:     make(map[int][]Cell, len(t.border)),
+		canon:      copyIDs(t.canon),
+		neighbors:  copyIDs(t.neighbors),
+		components: copyIDs(t.components),
+		holes:      append([]Cell(nil), t.holes...),
+		diag:       t.Diagnostics(),
+		rule:       t.rule,
+		states:     t.states,
+		nbhd:       t.nbhd,
+		radius:     t.radius,
+		offsets:    offsetTable{append([]Offset(nil), t.offsets[0]...), append([]Offset(nil), t.offsets[1]...)},
+		Cells:      make(map[int]Cell, len(t.Cells)),
+		Border:     make(map[int][]Cell, len(t.Border)),
+	}
+	for id, v := range t.border {
+		c.border[id] = append([]Cell(nil), v...)
+	}
+	for id, cell := range t.Cells {
+		c.Cells[id] = cell
+	}
+	for id, v := range t.Border {
+		c.Border[id] = append([]Cell(nil), v...)
+	}
+	return c
+}
+
+// copyIDs deep copies a slice of id slices.
+func copyIDs(ids [][]int) [][]int {
+	if ids == nil {
+		return nil
+	}
+	c := make([][]int, len(ids))
+	for i, v := range ids {
+		c[i] = append([]int(nil), v...)
+	}
+	return c
 }
 
 // Rows returns the number of rows in the underlying tile.
@@ -116,51 +614,583 @@ func (t *Pattern) Cols() int {
 	return t.cols
 }
 
+// findComponents groups the cell ids into pieces of cells that touch,
+// including diagonally. Pieces are ordered by their smallest id.
+func (t *Pattern) findComponents() [][]int {
+	seen := make([]bool, len(t.cells)+1)
+	var components [][]int
+	for id := 1; id <= len(t.cells); id++ {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		piece := []int{id}
+		for k := 0; k < len(piece); k++ {
+			c := t.cells[piece[k]-1]
+			for r := c.Row - 1; r <= c.Row+1; r++ {
+				for col := c.Col - 1; col <= c.Col+1; col++ {
+					if r < 0 || r >= t.rows || col < 0 || col >= t.cols {
+						continue
+					}
+					if n := t.mask[r][col]; n != 0 && !seen[n] {
+						seen[n] = true
+						piece = append(piece, n)
+					}
+				}
+			}
+		}
+		sort.Ints(piece)
+		components = append(components, piece)
+	}
+	return components
+}
+
+// Components returns the ids of the cells in each connected piece of the tile.
+// A tile may be made of several pieces as long as the rules fill the gaps
+// between them; New checks that.
+func (t *Pattern) Components() [][]int {
+	components := make([][]int, len(t.components))
+	for i, piece := range t.components {
+		components[i] = append([]int(nil), piece...)
+	}
+	return components
+}
+
+// findHoles finds the dead cells that can't be reached from the edge of
+// the mask through dead cells (up, down, left, right) and aren't on the border.
+// An enclosed region that one of the pieces would fit in is left out, as a
+// copy of that piece may be meant to fill it; finish then checks its
+// cells for coverage like any other neighbor of the tile.
+func findHoles(mask [][]bool, border map[Cell]claim, pieces [][]Cell) []Cell {
+	rows, cols := len(mask), len(mask[0])
+	outside := make([][]bool, rows)
+	for i := range outside {
+		outside[i] = make([]bool, cols)
+	}
+
+	// flood fill from the dead edge cells; fill marks the dead cells
+	// reachable from stack and returns them
+	steps := []Offset{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	fill := func(stack []Cell) []Cell {
+		var reached []Cell
+		for len(stack) > 0 {
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			reached = append(reached, c)
+			for _, d := range steps {
+				r, col := c.Row+d.Row, c.Col+d.Col
+				if r < 0 || r >= rows || col < 0 || col >= cols || outside[r][col] || mask[r][col] {
+					continue
+				}
+				outside[r][col] = true
+				stack = append(stack, Cell{r, col})
+			}
+		}
+		return reached
+	}
+	var edge []Cell
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if i == 0 || i == rows-1 || j == 0 || j == cols-1 {
+				outside[i][j] = true
+				edge = append(edge, Cell{i, j})
+			}
+		}
+	}
+	fill(edge)
+
+	// what is left dead are the enclosed regions, filled one at a time
+	var holes []Cell
+	for i, row := range mask {
+		for j, cell := range row {
+			if cell == alive || outside[i][j] {
+				continue
+			}
+			outside[i][j] = true
+			region := fill([]Cell{{i, j}})
+			if fitsPiece(region, pieces) {
+				continue
+			}
+			for _, c := range region {
+				if _, ok := border[c]; !ok {
+					holes = append(holes, c)
+				}
+			}
+		}
+	}
+	sort.Slice(holes, func(a, b int) bool {
+		if holes[a].Row != holes[b].Row {
+			return holes[a].Row < holes[b].Row
+		}
+		return holes[a].Col < holes[b].Col
+	})
+	return holes
+}
+
+// fitsPiece reports whether some translation of one of the pieces lies
+// entirely inside region.
+func fitsPiece(region []Cell, pieces [][]Cell) bool {
+	in := make(map[Cell]bool, len(region))
+	for _, c := range region {
+		in[c] = true
+	}
+	for _, piece := range pieces {
+		if len(piece) > len(region) {
+			continue
+		}
+		// line up the first cell of the piece with each cell of the region
+		for _, at := range region {
+			d := Offset{at.Row - piece[0].Row, at.Col - piece[0].Col}
+			fits := true
+			for _, c := range piece {
+				if !in[Cell{c.Row + d.Row, c.Col + d.Col}] {
+					fits = false
+					break
+				}
+			}
+			if fits {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Holes returns the dead cells enclosed by the tile, in row-major order.
+// No copy of the tile covers them, so Evolve always treats them as dead.
+// An enclosed gap that a piece of the tile would fit in is not a hole:
+// New requires the rules to cover it, see WithStrictCoverage.
+func (t *Pattern) Holes() []Cell {
+	return append([]Cell(nil), t.holes...)
+}
+
+// Diagnostics describes how New built the border from the rules.
+type Diagnostics struct {
+	// Rules are the rules that were applied, in order, including any
+	// negations added by WithSymmetricRules after the given rules.
+	Rules []Offset
+
+	// Contributed counts the border cells placed by each rule (same order as Rules).
+	Contributed []int
+
+	// Uncovered lists the neighbors of the tile that no rule covers.
+	// It is only ever non-empty with WithStrictCoverage(false).
+	Uncovered []Cell
+}
+
+// Rule returns the rule that decides the next state of a cell.
+func (t *Pattern) Rule() Rule {
+	return t.rule
+}
+
+// Neighborhood returns the neighborhood in which neighbors are counted.
+func (t *Pattern) Neighborhood() Neighborhood {
+	return t.nbhd
+}
+
+// Radius returns how far the neighborhood reaches, see WithRadius.
+func (t *Pattern) Radius() int {
+	return t.radius
+}
+
+// Rules returns a copy of the rules the Pattern was built with,
+// the same as Diagnostics().Rules.
+func (t *Pattern) Rules() []Offset {
+	return append([]Offset(nil), t.diag.Rules...)
+}
+
+// Diagnostics returns a copy of the diagnostics recorded by New.
+func (t *Pattern) Diagnostics() Diagnostics {
+	return Diagnostics{
+		Rules:       append([]Offset(nil), t.diag.Rules...),
+		Contributed: append([]int(nil), t.diag.Contributed...),
+		Uncovered:   append([]Cell(nil), t.diag.Uncovered...),
+	}
+}
+
+// Mask returns a copy of the mask the Pattern was built from:
+// true exactly where a cell of the tile is.
+func (t *Pattern) Mask() [][]bool {
+	mask := make([][]bool, t.rows)
+	underlying := make([]bool, t.rows*t.cols)
+	for i := range mask {
+		mask[i], underlying = underlying[:t.cols], underlying[t.cols:]
+		for j, id := range t.mask[i] {
+			mask[i][j] = id != 0
+		}
+	}
+	return mask
+}
+
+// InTile reports whether (row, col) is a cell of the tile.
+// Positions outside the array are not.
+func (t *Pattern) InTile(row, col int) bool {
+	if row < 0 || row >= t.rows || col < 0 || col >= t.cols {
+		return false
+	}
+	return t.mask[row][col] != 0
+}
+
+// Canonicalize returns the id of the tile cell at (row, col), or for a
+// border cell the id of the tile cell it is copied from.
+// ok is false anywhere else, including holes and outside the array.
+func (t *Pattern) Canonicalize(row, col int) (id int, ok bool) {
+	if row < 0 || row >= t.rows || col < 0 || col >= t.cols {
+		return 0, false
+	}
+	id = t.canon[row][col]
+	return id, id != 0
+}
+
+// Neighbors returns the ids of the neighbors of the cell with the given id,
+// in row-major order around it, with border cells resolved to their source.
+// Holes and uncovered positions are always dead and are left out, so a cell
+// can have fewer than eight. In a small tile the same id can appear more than
+// once, or be id itself, when copies of one cell surround it.
+// The result is a copy; it is nil if there is no such cell.
+func (t *Pattern) Neighbors(id int) []int {
+	if id < 1 || id > len(t.cells) {
+		return nil
+	}
+	return append([]int(nil), t.neighbors[id-1]...)
+}
+
+// NumCells returns the number of cells in the tile.
+// Cell ids run from 1 to NumCells() inclusive.
+func (t *Pattern) NumCells() int {
+	return len(t.cells)
+}
+
+// CellByID returns the coordinates of the cell with the given id.
+// ok is false if there is no such cell.
+func (t *Pattern) CellByID(id int) (c Cell, ok bool) {
+	if id < 1 || id > len(t.cells) {
+		return Cell{}, false
+	}
+	return t.cells[id-1], true
+}
+
+// BorderOf returns a copy of the border coordinates that take their value
+// from the cell with the given id.
+func (t *Pattern) BorderOf(id int) []Cell {
+	return append([]Cell(nil), t.border[id]...)
+}
+
+// TileFromIDs makes a tile for pat where exactly the cells with the given ids are alive.
+// Ids must be between 1 and pat.NumCells(); the first invalid id is reported.
+func TileFromIDs(pat *Pattern, ids []int) ([][]bool, error) {
+	tile := make([][]bool, pat.rows)
+	for i := range tile {
+		tile[i] = make([]bool, pat.cols)
+	}
+
+	for i, id := range ids {
+		c, ok := pat.CellByID(id)
+		if !ok {
+			return nil, fmt.Errorf("TileFromIDs: id %v at index %v is not a cell id (1 to %v)", id, i, len(pat.cells))
+		}
+		tile[c.Row][c.Col] = alive
+	}
+
+	return tile, nil
+}
+
+// LiveIDs returns the ids of the cells that are alive in tile, in ascending order.
+// tile must pass CheckTile, a smaller one can panic.
+func (t *Pattern) LiveIDs(tile [][]bool) []int {
+	var ids []int
+	t.EachAlive(tile, func(id int, _ Cell) bool {
+		ids = append(ids, id)
+		return true
+	})
+	return ids
+}
+
+// Randomize makes each tile cell of tile alive with probability density,
+// drawing from rng in id order so a seeded rng always gives the same tile.
+// Everything outside the tile cells is set dead.
+func (t *Pattern) Randomize(tile [][]bool, density float64, rng *rand.Rand) error {
+	if !(density >= 0 && density <= 1) {
+		return fmt.Errorf("Randomize: pattern: density %v is not between 0 and 1", density)
+	}
+	if err := t.CheckTile(tile); err != nil {
+		return fmt.Errorf("Randomize: pattern: %w", err)
+	}
+
+	for _, row := range tile {
+		for j := range row {
+			row[j] = dead
+		}
+	}
+	for _, c := range t.cells {
+		tile[c.Row][c.Col] = rng.Float64() < density
+	}
+	return nil
+}
+
+// Equal reports whether a and b have the same state in every tile cell.
+// The rest of the arrays, such as the border Evolve writes to, is ignored.
+// Both must pass CheckTile, a smaller one can panic.
+func (t *Pattern) Equal(a, b [][]bool) bool {
+	for _, c := range t.cells {
+		if a[c.Row][c.Col] != b[c.Row][c.Col] {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff returns the tile cells whose state differs between a and b,
+// in row-major order. Like Equal it ignores everything else, and a and b
+// must pass CheckTile.
+func (t *Pattern) Diff(a, b [][]bool) []Cell {
+	var diff []Cell
+	for _, c := range t.cells {
+		if a[c.Row][c.Col] != b[c.Row][c.Col] {
+			diff = append(diff, c)
+		}
+	}
+	return diff
+}
+
+// EachCell calls fn for every cell in the tile in ascending id order,
+// which is also row-major order.
+// Iteration stops as soon as fn returns false.
+func (t *Pattern) EachCell(fn func(id int, c Cell) bool) {
+	for i, c := range t.cells {
+		if !fn(i+1, c) {
+			return
+		}
+	}
+}
+
+// EachAlive is like EachCell but only visits the cells that are alive in tile.
+// tile must pass CheckTile, a smaller one can panic.
+func (t *Pattern) EachAlive(tile [][]bool, fn func(id int, c Cell) bool) {
+	t.EachCell(func(id int, c Cell) bool {
+		if tile[c.Row][c.Col] == alive {
+			return fn(id, c)
+		}
+		return true
+	})
+}
+
+// CheckTile returns an error wrapping ErrTileSize unless tile has exactly
+// Rows() rows of Cols() columns, the size of the mask the Pattern was built from.
+func (t *Pattern) CheckTile(tile [][]bool) error {
+	if len(tile) != t.rows {
+		return fmt.Errorf("%w: tile has %v rows, expected %v", ErrTileSize, len(tile), t.rows)
+	}
+	for i, row := range tile {
+		if len(row) != t.cols {
+			return fmt.Errorf("%w: tile row %v has %v columns, expected %v", ErrTileSize, i, len(row), t.cols)
+		}
+	}
+	return nil
+}
+
+// Population counts the live cells of the tile.
+// Live entries in tile outside the tile cells are ignored.
+// tile must pass CheckTile, a smaller one can panic.
+func (t *Pattern) Population(tile [][]bool) int {
+	return t.PopulationOf(tile, func(alive bool) bool { return alive })
+}
+
+// PopulationOf counts the tile cells whose state satisfies pred,
+// e.g. the dead cells with func(alive bool) bool { return !alive }.
+// Like Population it needs a tile that passes CheckTile.
+func (t *Pattern) PopulationOf(tile [][]bool, pred func(bool) bool) int {
+	n := 0
+	for _, c := range t.cells {
+		if pred(tile[c.Row][c.Col]) {
+			n++
+		}
+	}
+	return n
+}
+
 // Evolve finds the next generation in Conway's game of life
 // Argument tile will have a border added to it.
-func (t *Pattern) Evolve(tile [][]bool, newTile [][]bool) {
+// Evolve only reads the Pattern; it writes to tile's border and to newTile.
+// Both tiles must pass CheckTile, otherwise neither is touched and the error is returned.
+func (t *Pattern) Evolve(tile [][]bool, newTile [][]bool) error {
+	if err := t.checkTiles("Evolve", tile, newTile); err != nil {
+		return err
+	}
+	t.evolve(tile, newTile)
+	return nil
+}
 
-	// fill in the border around tile
-	// this is needed so the next generation is correct
-	for id, v := range t.Border {
-		tc := t.Cells[id] // find tile cell (tc) by id
-		// each border cell (bc) with the above id gets the value at tc
-		for _, bc := range v {
-			tile[bc.Row][bc.Col] = tile[tc.Row][tc.Col]
+// EvolveDelta is like Evolve but also appends to changed every tile cell
+// whose state differs between tile and newTile, in id order, and returns it.
+// Nothing is allocated if changed has room for them. No change means
+// tile is a still life.
+func (t *Pattern) EvolveDelta(tile, newTile [][]bool, changed []Cell) ([]Cell, error) {
+	if err := t.checkTiles("EvolveDelta", tile, newTile); err != nil {
+		return changed, err
+	}
+	t.fillBorder(tile)
+	for _, c := range t.cells {
+		next := t.evolveCell(tile, c.Row, c.Col)
+		newTile[c.Row][c.Col] = next
+		if next != tile[c.Row][c.Col] {
+			changed = append(changed, c)
 		}
 	}
+	return changed, nil
+}
 
-	for _, c := range t.Cells {
-		newTile[c.Row][c.Col] = evolveCell(tile, c.Row, c.Col)
+// EvolveCount is like EvolveDelta but only counts the cells that changed.
+func (t *Pattern) EvolveCount(tile, newTile [][]bool) (int, error) {
+	if err := t.checkTiles("EvolveCount", tile, newTile); err != nil {
+		return 0, err
+	}
+	t.fillBorder(tile)
+	n := 0
+	for _, c := range t.cells {
+		next := t.evolveCell(tile, c.Row, c.Col)
+		newTile[c.Row][c.Col] = next
+		if next != tile[c.Row][c.Col] {
+			n++
+		}
 	}
+	return n, nil
 }
 
-// evolveCell applies Conway's rules to find new state of cell
-func evolveCell(tile [][]bool, row, col int) bool {
-	// TODO check (row, col) in range of tile mask
+// Stats counts what happened to the tile cells in one generation.
+type Stats struct {
+	Births    int // dead cells that came alive
+	Deaths    int // live cells that died
+	Survivors int // live cells that stayed alive
+
+	// Population is the number of live cells after the generation,
+	// Survivors + Births unless Mutations flipped some.
+	Population int
+
+	// Mutations counts the tile cells flipped after the generation by
+	// WithMutation. They are not counted as births or deaths.
+	Mutations int
+}
 
-	currentState := tile[row][col]
-	liveNeighbors := countNeighbors(tile, row, col)
+// EvolveStats is like Evolve but also returns the Stats of the generation,
+// counted in the same pass.
+func (t *Pattern) EvolveStats(tile, newTile [][]bool) (Stats, error) {
+	if err := t.checkTiles("EvolveStats", tile, newTile); err != nil {
+		return Stats{}, err
+	}
+	return t.evolveStats(tile, newTile, nil), nil
+}
 
-	if currentState == alive {
-		if liveNeighbors < 2 { // lonely
-			return dead
+// evolveStats is EvolveStats for tiles that are known to fit, with the
+// rule made noisy by nz unless it is nil.
+func (t *Pattern) evolveStats(tile, newTile [][]bool, nz *noise) Stats {
+	t.fillBorder(tile)
+	var st Stats
+	for _, c := range t.cells {
+		was := tile[c.Row][c.Col]
+		next := t.evolveCell(tile, c.Row, c.Col)
+		if nz != nil {
+			next = nz.apply(was, next)
 		}
-		if liveNeighbors > 3 { // overpopulation
-			return dead
+		newTile[c.Row][c.Col] = next
+		switch {
+		case was && next:
+			st.Survivors++
+		case was:
+			st.Deaths++
+		case next:
+			st.Births++
 		}
+	}
+	st.Population = st.Survivors + st.Births
+	return st
+}
+
+// checkTiles runs CheckTile on both tiles given to the evolve method named op.
+func (t *Pattern) checkTiles(op string, tile, newTile [][]bool) error {
+	if err := t.CheckTile(tile); err != nil {
+		return fmt.Errorf("%v: pattern: %w", op, err)
+	}
+	if err := t.CheckTile(newTile); err != nil {
+		return fmt.Errorf("%v: pattern: newTile: %w", op, err)
+	}
+	return nil
+}
 
-		return alive // otherwise stable
+// evolve is Evolve for tiles that are known to fit.
+func (t *Pattern) evolve(tile [][]bool, newTile [][]bool) {
 
-	} else if liveNeighbors == 3 {
-		return alive // birth!
+	// fill in the border around tile
+	// this is needed so the next generation is correct
+	t.fillBorder(tile)
+
+	for _, c := range t.cells {
+		newTile[c.Row][c.Col] = t.evolveCell(tile, c.Row, c.Col)
 	}
+}
 
-	return dead // stays dead
+// EvolveN evolves tile n generations and returns the last one in a new array.
+// tile itself is not changed. If fn is not nil it is called with every
+// generation from 1 to n as it is made; fn must not keep the array, which is
+// reused. If fn returns false EvolveN stops and returns that generation.
+func (t *Pattern) EvolveN(tile [][]bool, n int, fn func(gen int, tile [][]bool) bool) ([][]bool, error) {
+	return t.EvolveNContext(context.Background(), tile, n, fn)
 }
 
-// countNeighbors counts the number of adjacent cells on the board that are live
-func countNeighbors(tile [][]bool, row, col int) int {
+// EvolveNContext is like EvolveN but also stops when ctx is done, checking it
+// between generations. It then returns the last generation made and ctx.Err().
+func (t *Pattern) EvolveNContext(ctx context.Context, tile [][]bool, n int, fn func(gen int, tile [][]bool) bool) ([][]bool, error) {
+	if err := t.CheckTile(tile); err != nil {
+		return nil, fmt.Errorf("EvolveN: pattern: %w", err)
+	}
+
+	sim := newSimulation(t, Tile{t.rows, t.cols, tile})
+	for sim.Generation() < n {
+		if err := ctx.Err(); err != nil {
+			return sim.cur.grid, err
+		}
+		sim.Step()
+		if fn != nil && !fn(sim.Generation(), sim.cur.grid) {
+			break
+		}
+	}
+	return sim.cur.grid, nil
+}
+
+// fillBorder copies the value of each tile cell into its border cells,
+// and clears the holes and any uncovered cells.
+func (t *Pattern) fillBorder(tile [][]bool) {
+	for _, h := range t.holes {
+		tile[h.Row][h.Col] = dead
+	}
+	for _, u := range t.diag.Uncovered {
+		tile[u.Row][u.Col] = dead
+	}
+
+	for id, v := range t.border {
+		tc := t.cells[id-1] // find tile cell (tc) by id
+		// each border cell (bc) with the above id gets the value at tc
+		for _, bc := range v {
+			tile[bc.Row][bc.Col] = tile[tc.Row][tc.Col]
+		}
+	}
+}
+
+// evolveCell applies the pattern's rule to find new state of cell
+func (t *Pattern) evolveCell(tile [][]bool, row, col int) bool {
+	// TODO check (row, col) in range of tile mask
+
+	return t.rule.next(tile[row][col], t.countNeighbors(tile, row, col))
+}
+
+// countNeighbors counts the live neighbors of a cell in the pattern's neighborhood.
+func (t *Pattern) countNeighbors(tile [][]bool, row, col int) int {
+	return countNeighbors(tile, row, col, t.offsets.at(row))
+}
+
+// countNeighbors counts the number of cells on the board that are live
+// at the given offsets from (row, col)
+func countNeighbors(tile [][]bool, row, col int, offsets []Offset) int {
 
 	// check if row or col are out of bounds
 	if row < 0 || row >= len(tile) || col < 0 || col >= len(tile[0]) {
@@ -169,18 +1199,14 @@ func countNeighbors(tile [][]bool, row, col int) int {
 
 	nNeighbors := 0
 
-	for r := row - 1; r <= row+1; r++ {
-		for c := col - 1; c <= col+1; c++ {
-			if r == row && c == col {
-				continue
-			}
-			if r < 0 || r >= len(tile) || c < 0 || c >= len(tile[0]) {
-				continue
-			}
+	for _, d := range offsets {
+		r, c := row+d.Row, col+d.Col
+		if r < 0 || r >= len(tile) || c < 0 || c >= len(tile[0]) {
+			continue
+		}
 
-			if tile[r][c] == alive {
-				nNeighbors++
-			}
+		if tile[r][c] == alive {
+			nNeighbors++
 		}
 	}
 
@@ -0,0 +1,47 @@
+package pattern
+
+import "fmt"
+
+// DetectPeriod evolves tile until its state repeats and returns how many
+// generations came before the cycle (transient) and the length of the cycle
+// (period). Only tile cells are compared. A still life has period 1 and a
+// blinker period 2. If no state repeats within maxGens generations the error
+// wraps ErrNoPeriod, and maxGens must not be negative. tile is not changed.
+func DetectPeriod(pat *Pattern, tile [][]bool, maxGens int) (transient, period int, err error) {
+	if err := pat.CheckTile(tile); err != nil {
+		return 0, 0, fmt.Errorf("DetectPeriod: pattern: %w", err)
+	}
+	if maxGens < 0 {
+		return 0, 0, fmt.Errorf("DetectPeriod: pattern: can't evolve %v generations", maxGens)
+	}
+
+	// generation at which each state was first seen, keyed by the packed state
+	seen := make(map[string]int)
+	sim := newSimulation(pat, Tile{pat.rows, pat.cols, tile})
+	buf := make([]byte, (len(pat.cells)+7)/8)
+	for {
+		key := string(pat.pack(sim.cur.grid, buf))
+		if first, ok := seen[key]; ok {
+			return first, sim.Generation() - first, nil
+		}
+		if sim.Generation() == maxGens {
+			return 0, 0, fmt.Errorf("DetectPeriod: pattern: %w within %v generations", ErrNoPeriod, maxGens)
+		}
+		seen[key] = sim.Generation()
+		sim.Step()
+	}
+}
+
+// pack stores the states of the tile cells of tile in buf, one bit each
+// in id order, and returns it. buf must hold NumCells() bits.
+func (t *Pattern) pack(tile [][]bool, buf []byte) []byte {
+	for i := range buf {
+		buf[i] = 0
+	}
+	for i, c := range t.cells {
+		if tile[c.Row][c.Col] {
+			buf[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return buf
+}
@@ -0,0 +1,131 @@
+package pattern
+
+import "fmt"
+
+// ReferenceEvolve returns the next generation of grid in plain Conway's
+// game of life, with everything outside grid dead. It knows nothing about
+// tiles and is kept simple on purpose, as a check on Evolve, see CrossCheck.
+func ReferenceEvolve(grid [][]bool) [][]bool {
+	return referenceEvolve(grid, Conway, Moore, 1)
+}
+
+// referenceEvolve is ReferenceEvolve under rule in neighborhood nbhd of the given radius.
+func referenceEvolve(grid [][]bool, rule Rule, nbhd Neighborhood, radius int) [][]bool {
+	next := make([][]bool, len(grid))
+	for i, row := range grid {
+		next[i] = make([]bool, len(row))
+		for j, cell := range row {
+			n := 0
+			for r := i - radius; r <= i+radius; r++ {
+				for c := j - radius; c <= j+radius; c++ {
+					if !near(nbhd, radius, i, j, r, c) {
+						continue
+					}
+					if (r != i || c != j) && r >= 0 && r < len(grid) && c >= 0 && c < len(grid[r]) && grid[r][c] {
+						n++
+					}
+				}
+			}
+			if cell {
+				next[i][j] = rule.survive.has(n)
+			} else {
+				next[i][j] = rule.birth.has(n)
+			}
+		}
+	}
+	return next
+}
+
+// near reports whether (r, c) is within radius of (i, j) in nbhd, for a
+// cell in the square of that radius around (i, j). It measures distances
+// instead of using the offsets Evolve counts with.
+func near(nbhd Neighborhood, radius, i, j, r, c int) bool {
+	switch nbhd {
+	case VonNeumann:
+		return abs(r-i)+abs(c-j) <= radius // steps along rows and columns
+	case Hex:
+		return hexDistance(i, j, r, c) <= radius
+	}
+	return true
+}
+
+// referenceGenerations is referenceEvolve for a GenerationsRule on a
+// multi-state grid. Only cells in state 1 count as live neighbors.
+func referenceGenerations(grid Grid, g GenerationsRule, nbhd Neighborhood, radius int) Grid {
+	next := NewGrid(grid.rows, grid.cols)
+	for i, row := range grid.cells {
+		for j, cell := range row {
+			n := 0
+			for r := i - radius; r <= i+radius; r++ {
+				for c := j - radius; c <= j+radius; c++ {
+					if !near(nbhd, radius, i, j, r, c) {
+						continue
+					}
+					if (r != i || c != j) && grid.Get(r, c) == 1 {
+						n++
+					}
+				}
+			}
+			next.cells[i][j] = g.next(cell, n)
+		}
+	}
+	return next
+}
+
+// CrossCheck evolves tile gens generations with Evolve and checks every
+// generation against ReferenceEvolve, with the pattern's rule and
+// neighborhood, run on the tile unfolded into the plane.
+// It returns an error naming the first generation and cell that differ,
+// and gens must not be negative. tile is not changed.
+func CrossCheck(pat *Pattern, tile [][]bool, gens int) error {
+	if err := pat.CheckTile(tile); err != nil {
+		return fmt.Errorf("CrossCheck: pattern: %w", err)
+	}
+	if gens < 0 {
+		return fmt.Errorf("CrossCheck: pattern: can't evolve %v generations", gens)
+	}
+
+	sim := newSimulation(pat, Tile{pat.rows, pat.cols, tile})
+	for sim.Generation() < gens {
+		// the unfolded array holds the tile and the copies around it
+		plane := referenceEvolve(pat.Unfold(sim.cur.grid, 1, 1), pat.rule, pat.nbhd, pat.radius)
+		sim.Step()
+		for i, c := range pat.cells {
+			if got, want := sim.cur.grid[c.Row][c.Col], plane[c.Row][c.Col]; got != want {
+				return fmt.Errorf("CrossCheck: pattern: generation %v differs at id:%v r:%v c:%v: Evolve gives %v, the plane gives %v",
+					sim.Generation(), i+1, c.Row, c.Col, state(got), state(want))
+			}
+		}
+	}
+	return nil
+}
+
+// CrossCheckGrid is CrossCheck for EvolveGenerations: it checks every
+// generation of grid against the pattern's GenerationsRule run on the grid
+// unfolded into the plane, so states that cross the seams of the tile are
+// checked too. gens must not be negative. grid is not changed.
+func CrossCheckGrid(pat *Pattern, grid Grid, gens int) error {
+	if err := pat.CheckGrid(grid); err != nil {
+		return fmt.Errorf("CrossCheckGrid: pattern: %w", err)
+	}
+	if gens < 0 {
+		return fmt.Errorf("CrossCheckGrid: pattern: can't evolve %v generations", gens)
+	}
+
+	cur, next := NewGrid(pat.rows, pat.cols), NewGrid(pat.rows, pat.cols)
+	cur.CopyFrom(grid)
+	g := pat.GenerationsRule()
+	for gen := 1; gen <= gens; gen++ {
+		// the unfolded array holds the tile and the copies around it
+		plane := referenceGenerations(pat.unfoldGrid(cur, 1, 1), g, pat.nbhd, pat.radius)
+		pat.EvolveGenerations(cur, next)
+		cur, next = next, cur
+		for i, c := range pat.cells {
+			if got, want := cur.cells[c.Row][c.Col], plane.cells[c.Row][c.Col]; got != want {
+				return fmt.Errorf("CrossCheckGrid: pattern: generation %v differs at id:%v r:%v c:%v: EvolveGenerations gives %v, the plane gives %v",
+					gen, i+1, c.Row, c.Col, got, want)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package pattern
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rule says for which numbers of live neighbors a dead cell comes alive
+// (birth) and a live cell stays alive (survival), as in the rulestring
+// B3/S23 of Conway's game of life. The numbers go up to maxCount, enough
+// for the Moore neighborhood of radius maxRadius.
+type Rule struct {
+	birth, survive counts
+}
+
+// counts is a set of numbers of neighbors.
+type counts [4]uint64
+
+// add puts n in the set.
+func (c *counts) add(n int) {
+	c[n/64] |= 1 << uint(n%64)
+}
+
+// has reports whether n is in the set.
+func (c counts) has(n int) bool {
+	return n >= 0 && n < 64*len(c) && c[n/64]&(1<<uint(n%64)) != 0
+}
+
+// Conway is B3/S23, the rule of Conway's game of life and the default for New.
+var Conway = Rule{birth: counts{1 << 3}, survive: counts{1<<2 | 1<<3}}
+
+// maxCount is the number of neighbors of a cell in the largest
+// neighborhood, Moore with radius maxRadius. New checks a rule against the
+// pattern's neighborhood.
+const maxCount = (2*maxRadius+1)*(2*maxRadius+1) - 1
+
+// ParseRule parses a rulestring such as "B3/S23" (Conway) or "B36/S23"
+// (HighLife): B followed by the numbers of live neighbors for a birth and
+// S followed by the numbers for survival, in either order and either case.
+// Each digit is a number, unless the part has a comma or a dash: then it is
+// a comma list of numbers and ranges, for the larger neighborhoods of
+// WithRadius, e.g. "B34-45/S34-58" or "B10,12/S9-11". New rejects a rule
+// that counts more neighbors than its neighborhood has, e.g. 5 in the von
+// Neumann neighborhood of radius 1. Errors wrap ErrRuleString.
+func ParseRule(s string) (Rule, error) {
+	var r Rule
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return Rule{}, fmt.Errorf("ParseRule: pattern: %w: %q is not B.../S...", ErrRuleString, s)
+	}
+
+	var seen [2]bool
+	for _, part := range parts {
+		var set *counts
+		var k int
+		switch {
+		case strings.HasPrefix(part, "B") || strings.HasPrefix(part, "b"):
+			set, k = &r.birth, 0
+		case strings.HasPrefix(part, "S") || strings.HasPrefix(part, "s"):
+			set, k = &r.survive, 1
+		default:
+			return Rule{}, fmt.Errorf("ParseRule: pattern: %w: %q in %q does not start with B or S", ErrRuleString, part, s)
+		}
+		if seen[k] {
+			return Rule{}, fmt.Errorf("ParseRule: pattern: %w: %q has two %c parts", ErrRuleString, s, part[0])
+		}
+		seen[k] = true
+
+		if strings.ContainsAny(part, ",-") {
+			if err := set.parseList(part[1:]); err != nil {
+				return Rule{}, fmt.Errorf("ParseRule: pattern: %w: %v in %q", ErrRuleString, err, s)
+			}
+			continue
+		}
+		for _, d := range part[1:] {
+			if d < '0' || d > '9' {
+				return Rule{}, fmt.Errorf("ParseRule: pattern: %w: %q in %q is not a number of neighbors", ErrRuleString, d, s)
+			}
+			set.add(int(d - '0'))
+		}
+	}
+	return r, nil
+}
+
+// parseList adds the numbers in a comma list of numbers and ranges like "2,4-6".
+func (c *counts) parseList(list string) error {
+	for _, item := range strings.Split(list, ",") {
+		lo, hi := item, item
+		if i := strings.Index(item, "-"); i >= 0 {
+			lo, hi = item[:i], item[i+1:]
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		switch {
+		case err1 != nil || err2 != nil || from < 0 || to < from:
+			return fmt.Errorf("%q is not a number or range of neighbors", item)
+		case to > maxCount:
+			return fmt.Errorf("%v is more than the %v neighbors of the largest neighborhood", to, maxCount)
+		}
+		for n := from; n <= to; n++ {
+			c.add(n)
+		}
+	}
+	return nil
+}
+
+// String returns the rule as a rulestring, e.g. "B3/S23", with comma lists
+// if it counts more than 9 neighbors.
+func (r Rule) String() string {
+	list := r.max() > 9
+	return "B" + r.birth.format(list) + "/S" + r.survive.format(list)
+}
+
+// format returns the numbers as digits, or with list set as a comma list
+// that ParseRule reads back, e.g. "2,4-6".
+func (c counts) format(list bool) string {
+	var b strings.Builder
+	for n := 0; n <= maxCount; n++ {
+		if !c.has(n) {
+			continue
+		}
+		if !list {
+			fmt.Fprint(&b, n)
+			continue
+		}
+		end := n
+		for c.has(end + 1) {
+			end++
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		if end > n {
+			fmt.Fprintf(&b, "%v-%v", n, end)
+		} else {
+			fmt.Fprint(&b, n)
+		}
+		n = end
+	}
+	s := b.String()
+	if list && s != "" && !strings.ContainsAny(s, ",-") {
+		s += "-" + s // a lone number would read as digits
+	}
+	return s
+}
+
+// max returns the largest number of neighbors the rule mentions, or -1 if none.
+func (r Rule) max() int {
+	for n := 64*len(r.birth) - 1; n >= 0; n-- {
+		if r.birth.has(n) || r.survive.has(n) {
+			return n
+		}
+	}
+	return -1
+}
+
+// next is the state after a cell in state s with n live neighbors.
+func (r Rule) next(s bool, n int) bool {
+	if s == alive {
+		return r.survive.has(n)
+	}
+	return r.birth.has(n)
+}
+
+// Reasons given by reason for a cell's next state.
+const (
+	reasonLonely         = "lonely"
+	reasonOverpopulation = "overpopulation"
+	reasonDies           = "dies"
+	reasonStable         = "stable"
+	reasonBirth          = "birth"
+	reasonStaysDead      = "stays dead"
+)
+
+// reason names the part of the rule that took a cell from currentState to
+// next with liveNeighbors, as decided by next. A live cell that dies is
+// lonely if it has fewer live neighbors than any number that survives,
+// and overpopulated if it has more than all of them.
+func (r Rule) reason(currentState, next bool, liveNeighbors int) string {
+	switch {
+	case currentState == dead && next == alive:
+		return reasonBirth // birth!
+	case currentState == dead:
+		return reasonStaysDead
+	case next == alive:
+		return reasonStable
+	}
+	fewer, more := false, false
+	for n := 0; n < 64*len(r.survive); n++ {
+		if r.survive.has(n) {
+			fewer = fewer || n > liveNeighbors
+			more = more || n < liveNeighbors
+		}
+	}
+	switch {
+	case !more:
+		return reasonLonely
+	case !fewer:
+		return reasonOverpopulation
+	}
+	return reasonDies
+}
@@ -0,0 +1,102 @@
+package pattern
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LoadRuleTable reads a rule as a table of transitions, one per line:
+// the state of a cell (0 dead, 1 alive), its number of live neighbors and
+// its next state, e.g. "0 3 1" for a birth on 3, optionally written
+// "0 3 -> 1". Blank lines and lines starting with # are skipped. A
+// transition the table leaves out keeps a dead cell dead and kills a live
+// one; use LoadRuleTableStrict to require every transition. Errors,
+// including a transition listed twice, wrap ErrRuleTable.
+func LoadRuleTable(r io.Reader) (Rule, error) {
+	return loadRuleTable("LoadRuleTable", r, -1)
+}
+
+// LoadRuleTableStrict is LoadRuleTable for a neighborhood of the given
+// number of neighbors, see Neighborhood.Size: the table must list both
+// states with every number of live neighbors from 0 to neighbors, and
+// nothing more.
+func LoadRuleTableStrict(r io.Reader, neighbors int) (Rule, error) {
+	if neighbors < 0 || neighbors > maxCount {
+		return Rule{}, fmt.Errorf("LoadRuleTableStrict: pattern: %w: %v neighbors, expected 0 to %v", ErrRuleTable, neighbors, maxCount)
+	}
+	return loadRuleTable("LoadRuleTableStrict", r, neighbors)
+}
+
+// loadRuleTable reads a table for LoadRuleTable, or if neighbors is not
+// negative for LoadRuleTableStrict.
+func loadRuleTable(op string, r io.Reader, neighbors int) (Rule, error) {
+	var rule Rule
+	var listed [2]counts
+	limit := maxCount
+	if neighbors >= 0 {
+		limit = neighbors
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var fields []string
+		for _, f := range strings.Fields(text) {
+			if f != "->" {
+				fields = append(fields, f)
+			}
+		}
+		if len(fields) != 3 {
+			return Rule{}, fmt.Errorf("%s: pattern: %w: line %v: %q is not state, neighbors and next state", op, ErrRuleTable, line, text)
+		}
+		state, err1 := strconv.Atoi(fields[0])
+		n, err2 := strconv.Atoi(fields[1])
+		next, err3 := strconv.Atoi(fields[2])
+		switch {
+		case err1 != nil || state < 0 || state > 1:
+			return Rule{}, fmt.Errorf("%s: pattern: %w: line %v: state %q is not 0 or 1", op, ErrRuleTable, line, fields[0])
+		case err2 != nil || n < 0 || n > limit:
+			return Rule{}, fmt.Errorf("%s: pattern: %w: line %v: %q is not a number of neighbors from 0 to %v", op, ErrRuleTable, line, fields[1], limit)
+		case err3 != nil || next < 0 || next > 1:
+			return Rule{}, fmt.Errorf("%s: pattern: %w: line %v: next state %q is not 0 or 1", op, ErrRuleTable, line, fields[2])
+		case listed[state].has(n):
+			return Rule{}, fmt.Errorf("%s: pattern: %w: line %v: state %v with %v neighbors is listed twice", op, ErrRuleTable, line, state, n)
+		}
+		listed[state].add(n)
+		if next == 0 {
+			continue
+		}
+		if state == 0 {
+			rule.birth.add(n)
+		} else {
+			rule.survive.add(n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Rule{}, fmt.Errorf("%s: pattern: %w", op, err)
+	}
+
+	if neighbors >= 0 {
+		missing, first := 0, ""
+		for state := range listed {
+			for n := 0; n <= neighbors; n++ {
+				if !listed[state].has(n) {
+					if missing == 0 {
+						first = fmt.Sprintf("state %v with %v neighbors", state, n)
+					}
+					missing++
+				}
+			}
+		}
+		if missing > 0 {
+			return Rule{}, fmt.Errorf("%s: pattern: %w: %v transitions missing, the first is %v", op, ErrRuleTable, missing, first)
+		}
+	}
+	return rule, nil
+}
@@ -0,0 +1,254 @@
+package pattern
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// ErrStop can be returned by a generation hook to end Run early without an error.
+var ErrStop = errors.New("stop")
+
+// Hook is called by Run with every generation the simulation reaches.
+// tile is the Current tile and is only valid during the call.
+// Returning ErrStop (possibly wrapped) ends Run with no error;
+// any other error ends it with that error.
+type Hook func(gen int, tile Tile) error
+
+// Simulation evolves a tile one generation at a time.
+// It owns the two tiles Evolve needs and swaps them after every Step,
+// and the random state of WithNoise and WithMutation. A Simulation is not
+// safe for concurrent use, but many can share one Pattern.
+type Simulation struct {
+	pat       *Pattern
+	cur, next Tile
+	gen       int
+	stats     Stats
+
+	hooks []Hook
+
+	// hooked is the last generation the hooks were called with, -1 for none.
+	hooked int
+
+	// history is a ring of copies of the latest generations before the
+	// current one, oldest at histStart, see WithHistory. NewSimulation
+	// allocates histLen tiles for it.
+	history             []Tile
+	histLen             int
+	histStart, histSize int
+
+	// mutation is the probability that Step flips a tile cell, drawn from
+	// rng, see WithMutation.
+	mutation float64
+	rng      *rand.Rand
+
+	// noise makes Step apply the rule with probabilities, see WithNoise.
+	// It is nil for the exact rule.
+	noise *noise
+}
+
+// SimulationOption changes how NewSimulation sets up a Simulation.
+type SimulationOption func(*Simulation)
+
+// WithHistory keeps copies of the n generations before the current one so
+// Back can return to them. NewSimulation allocates n tiles of Rows() x Cols()
+// cells up front, and returns an error wrapping ErrBadHistory if n is negative.
+func WithHistory(n int) SimulationOption {
+	return func(s *Simulation) {
+		s.histLen = n
+	}
+}
+
+// WithMutation makes every Step, after evolving the tile, flip each tile
+// cell with probability p, drawing from rng in id order so that a seeded
+// rng always gives the same generations. The hooks see the tile after the
+// flips, and Stats counts them as Mutations. rng is only used, and only
+// needs to be non-nil, when p is above 0.
+func WithMutation(p float64, rng *rand.Rand) SimulationOption {
+	return func(s *Simulation) {
+		s.mutation, s.rng = p, rng
+	}
+}
+
+// NewSimulation starts a simulation of pat at generation 0 with a copy of initial.
+// It returns an error wrapping ErrTileSize if initial does not fit pat,
+// ErrBadHistory for a bad WithHistory, or ErrBadProbability for a bad
+// WithMutation or WithNoise.
+func NewSimulation(pat *Pattern, initial Tile, opts ...SimulationOption) (*Simulation, error) {
+	if err := pat.CheckTile(initial.grid); err != nil {
+		return nil, fmt.Errorf("NewSimulation: pattern: %w", err)
+	}
+	s := newSimulation(pat, initial)
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.histLen < 0 {
+		return nil, fmt.Errorf("NewSimulation: pattern: %w: can't keep %v generations", ErrBadHistory, s.histLen)
+	}
+	switch {
+	case !(s.mutation >= 0 && s.mutation <= 1):
+		return nil, fmt.Errorf("NewSimulation: pattern: %w: mutation %v is not between 0 and 1", ErrBadProbability, s.mutation)
+	case s.mutation > 0 && s.rng == nil:
+		return nil, fmt.Errorf("NewSimulation: pattern: %w: mutation %v needs a random number generator", ErrBadProbability, s.mutation)
+	}
+	if s.noise != nil {
+		p := s.noise.p
+		for _, q := range []float64{p.Birth, p.Death} {
+			if !(q >= 0 && q <= 1) {
+				return nil, fmt.Errorf("NewSimulation: pattern: %w: noise %v is not between 0 and 1", ErrBadProbability, q)
+			}
+		}
+		switch {
+		case p == Certain:
+			s.noise = nil
+		case s.noise.rng == nil:
+			return nil, fmt.Errorf("NewSimulation: pattern: %w: noise %+v needs a random number generator", ErrBadProbability, p)
+		}
+	}
+	s.history = make([]Tile, s.histLen)
+	for i := range s.history {
+		s.history[i] = NewTile(pat.rows, pat.cols)
+	}
+	return s, nil
+}
+
+// newSimulation is NewSimulation for a tile that is known to fit.
+func newSimulation(pat *Pattern, initial Tile) *Simulation {
+	s := &Simulation{
+		pat:    pat,
+		cur:    NewTile(pat.rows, pat.cols),
+		next:   NewTile(pat.rows, pat.cols),
+		hooked: -1,
+	}
+	for i, row := range initial.grid {
+		copy(s.cur.grid[i], row)
+	}
+	return s
+}
+
+// Step evolves the current tile by one generation.
+func (s *Simulation) Step() {
+	if len(s.history) > 0 {
+		// the copy is taken before Evolve writes the border of s.cur
+		i := (s.histStart + s.histSize) % len(s.history)
+		s.history[i].CopyFrom(s.cur)
+		if s.histSize < len(s.history) {
+			s.histSize++
+		} else {
+			s.histStart = (s.histStart + 1) % len(s.history)
+		}
+	}
+	s.stats = s.pat.evolveStats(s.cur.grid, s.next.grid, s.noise)
+	if s.mutation > 0 {
+		s.mutate(s.next.grid)
+	}
+	s.cur, s.next = s.next, s.cur
+	s.gen++
+}
+
+// mutate flips the tile cells of tile with probability s.mutation and
+// counts them in s.stats.
+func (s *Simulation) mutate(tile [][]bool) {
+	for _, c := range s.pat.cells {
+		if s.rng.Float64() >= s.mutation {
+			continue
+		}
+		tile[c.Row][c.Col] = !tile[c.Row][c.Col]
+		s.stats.Mutations++
+		if tile[c.Row][c.Col] {
+			s.stats.Population++
+		} else {
+			s.stats.Population--
+		}
+	}
+}
+
+// Current returns the tile of the current generation.
+// It is only valid until the next Step, which reuses its cells.
+func (s *Simulation) Current() Tile {
+	return s.cur
+}
+
+// Stats returns the Stats of the last Step; they are all zero before the first.
+func (s *Simulation) Stats() Stats {
+	return s.stats
+}
+
+// Back rewinds the simulation k generations using the history kept by
+// WithHistory, and returns an error if it doesn't go back that far.
+// Stats are zero after Back, and the hooks are not called with the generation
+// it returns to, only with the ones Run reaches after it.
+func (s *Simulation) Back(k int) error {
+	if k < 0 || k > s.histSize {
+		return fmt.Errorf("Back: pattern: can't go back %v generations, history has %v", k, s.histSize)
+	}
+	if k == 0 {
+		return nil
+	}
+	s.histSize -= k
+	s.cur.CopyFrom(s.history[(s.histStart+s.histSize)%len(s.history)])
+	s.gen -= k
+	s.stats = Stats{}
+	s.hooked = s.gen
+	return nil
+}
+
+// Generation returns the number of the current generation: the Steps taken less any Back.
+func (s *Simulation) Generation() int {
+	return s.gen
+}
+
+// OnGeneration registers fn to be called by Run.
+// Hooks are called in the order they were registered.
+func (s *Simulation) OnGeneration(fn Hook) {
+	s.hooks = append(s.hooks, fn)
+}
+
+// Run steps the simulation until generation n, calling the hooks with
+// each generation, starting with the current one if they haven't seen it,
+// e.g. generation 0 on the first Run. It stops at the first hook error;
+// see Hook. Run returns right away if the simulation is already at n.
+func (s *Simulation) Run(n int) error {
+	return s.RunContext(context.Background(), n)
+}
+
+// RunContext is like Run but also stops when ctx is done, returning ctx.Err().
+// ctx is checked between generations, so the hooks always finish the
+// generation they are called with, and Current is the last generation reached.
+func (s *Simulation) RunContext(ctx context.Context, n int) error {
+	if s.hooked < s.gen {
+		if err := s.callHooks(); err != nil {
+			return stopped(err)
+		}
+	}
+	for s.gen < n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s.Step()
+		if err := s.callHooks(); err != nil {
+			return stopped(err)
+		}
+	}
+	return nil
+}
+
+// callHooks calls the hooks with the current generation.
+func (s *Simulation) callHooks() error {
+	s.hooked = s.gen
+	for _, fn := range s.hooks {
+		if err := fn(s.gen, s.cur); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stopped turns ErrStop into a clean end of Run.
+func stopped(err error) error {
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	return err
+}
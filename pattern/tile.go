@@ -0,0 +1,99 @@
+package pattern
+
+import "fmt"
+
+// Tile is a rectangular grid of cell states, true meaning alive.
+// Unlike a [][]bool it can't have rows of different lengths.
+// Like a slice, a copied Tile shares its cells; use CopyFrom for a real copy.
+type Tile struct {
+	rows, cols int
+	grid       [][]bool
+}
+
+// NewTile makes a tile of rows x cols dead cells.
+// Use NewTile(pat.Rows(), pat.Cols()) for a tile that fits pat.
+// It panics if rows or cols is negative or the tile has more than 1<<26 cells.
+func NewTile(rows, cols int) Tile {
+	if rows < 0 || cols < 0 || cols > 0 && rows > maxCells/cols {
+		panic(fmt.Sprintf("pattern: NewTile %vx%v", rows, cols))
+	}
+	grid := make([][]bool, rows)
+	underlying := make([]bool, rows*cols)
+	for i := range grid {
+		grid[i], underlying = underlying[:cols], underlying[cols:]
+	}
+	return Tile{rows, cols, grid}
+}
+
+// TileFrom wraps grid, e.g. a tile read from a CSV, without copying it.
+// It returns an error wrapping ErrTileSize if the rows differ in length.
+func TileFrom(grid [][]bool) (Tile, error) {
+	t := Tile{rows: len(grid), grid: grid}
+	if len(grid) > 0 {
+		t.cols = len(grid[0])
+	}
+	for i, row := range grid {
+		if len(row) != t.cols {
+			return Tile{}, fmt.Errorf("TileFrom: pattern: %w: tile row %v has %v columns, expected %v", ErrTileSize, i, len(row), t.cols)
+		}
+	}
+	return t, nil
+}
+
+// Rows returns the number of rows in the tile.
+func (t Tile) Rows() int {
+	return t.rows
+}
+
+// Cols returns the number of columns in the tile.
+func (t Tile) Cols() int {
+	return t.cols
+}
+
+// Get returns the state at (r, c); positions outside the tile are dead.
+func (t Tile) Get(r, c int) bool {
+	if r < 0 || r >= t.rows || c < 0 || c >= t.cols {
+		return dead
+	}
+	return t.grid[r][c]
+}
+
+// Set sets the state at (r, c). It panics, saying why, if (r, c) is outside the tile.
+func (t Tile) Set(r, c int, v bool) {
+	if r < 0 || r >= t.rows || c < 0 || c >= t.cols {
+		panic(fmt.Sprintf("pattern: Set r:%v c:%v outside the %vx%v tile", r, c, t.rows, t.cols))
+	}
+	t.grid[r][c] = v
+}
+
+// Fill sets every cell of the tile to v.
+func (t Tile) Fill(v bool) {
+	for _, row := range t.grid {
+		for j := range row {
+			row[j] = v
+		}
+	}
+}
+
+// CopyFrom copies the cells of other into t.
+// It returns an error wrapping ErrTileSize if the sizes differ.
+func (t Tile) CopyFrom(other Tile) error {
+	if t.rows != other.rows || t.cols != other.cols {
+		return fmt.Errorf("CopyFrom: pattern: %w: tile is %vx%v, expected %vx%v", ErrTileSize, other.rows, other.cols, t.rows, t.cols)
+	}
+	for i, row := range other.grid {
+		copy(t.grid[i], row)
+	}
+	return nil
+}
+
+// Grid returns the cells as a [][]bool for the functions that take one.
+// It shares memory with the tile.
+func (t Tile) Grid() [][]bool {
+	return t.grid
+}
+
+// EvolveTile is Evolve for tiles.
+func (t *Pattern) EvolveTile(tile, newTile Tile) error {
+	return t.Evolve(tile.grid, newTile.grid)
+}
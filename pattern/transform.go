@@ -0,0 +1,92 @@
+package pattern
+
+import "fmt"
+
+// Rotate90 returns a copy of tile with the live cells turned a quarter turn
+// clockwise about the center of the tile's bounding box.
+// If the box isn't square the turned box has the other shape, so for an odd
+// difference between its sides the center moves by half a cell (rounded down).
+// A live cell that lands outside the tile is dropped, or with strict set is
+// reported as an error. Four turns give back the original when the tile has
+// that symmetry and its bounding box is square.
+func (t *Pattern) Rotate90(tile [][]bool, strict bool) ([][]bool, error) {
+	lo, hi := t.bounds()
+	h, w := hi.Row-lo.Row+1, hi.Col-lo.Col+1
+	top := Cell{lo.Row + floorHalf(h-w), lo.Col + floorHalf(w-h)}
+	return t.transform("Rotate90", tile, strict, func(c Cell) Cell {
+		return Cell{top.Row + c.Col - lo.Col, top.Col + hi.Row - c.Row}
+	})
+}
+
+// FlipH returns a copy of tile with the live cells mirrored left to right
+// within the tile's bounding box. Cells landing outside the tile are handled
+// as in Rotate90.
+func (t *Pattern) FlipH(tile [][]bool, strict bool) ([][]bool, error) {
+	lo, hi := t.bounds()
+	return t.transform("FlipH", tile, strict, func(c Cell) Cell {
+		return Cell{c.Row, lo.Col + hi.Col - c.Col}
+	})
+}
+
+// FlipV returns a copy of tile with the live cells mirrored top to bottom
+// within the tile's bounding box. Cells landing outside the tile are handled
+// as in Rotate90.
+func (t *Pattern) FlipV(tile [][]bool, strict bool) ([][]bool, error) {
+	lo, hi := t.bounds()
+	return t.transform("FlipV", tile, strict, func(c Cell) Cell {
+		return Cell{lo.Row + hi.Row - c.Row, c.Col}
+	})
+}
+
+// transform moves every live tile cell of tile with move into a new tile.
+// op names the caller in errors.
+func (t *Pattern) transform(op string, tile [][]bool, strict bool, move func(Cell) Cell) ([][]bool, error) {
+	if err := t.CheckTile(tile); err != nil {
+		return nil, fmt.Errorf("%v: pattern: %w", op, err)
+	}
+
+	moved := NewTile(t.rows, t.cols)
+	for _, c := range t.cells {
+		if !tile[c.Row][c.Col] {
+			continue
+		}
+		to := move(c)
+		if !t.InTile(to.Row, to.Col) {
+			if strict {
+				return nil, fmt.Errorf("%v: pattern: live cell r:%v c:%v lands outside the tile at r:%v c:%v",
+					op, c.Row, c.Col, to.Row, to.Col)
+			}
+			continue
+		}
+		moved.grid[to.Row][to.Col] = alive
+	}
+	return moved.grid, nil
+}
+
+// bounds returns the top left and bottom right corners of the tile cells.
+func (t *Pattern) bounds() (lo, hi Cell) {
+	lo, hi = t.cells[0], t.cells[0]
+	for _, c := range t.cells {
+		if c.Row < lo.Row {
+			lo.Row = c.Row
+		}
+		if c.Col < lo.Col {
+			lo.Col = c.Col
+		}
+		if c.Row > hi.Row {
+			hi.Row = c.Row
+		}
+		if c.Col > hi.Col {
+			hi.Col = c.Col
+		}
+	}
+	return lo, hi
+}
+
+// floorHalf is x/2 rounded down.
+func floorHalf(x int) int {
+	if x < 0 {
+		return -((1 - x) / 2)
+	}
+	return x / 2
+}
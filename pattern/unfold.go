@@ -0,0 +1,122 @@
+package pattern
+
+import "fmt"
+
+// Shifts finds every shift of the tile, made of sums of the rules, that
+// puts part of it inside an area of copiesV x copiesH arrays with the
+// original array in its top left corner. The origin is always first.
+func (t *Pattern) Shifts(copiesH, copiesV int) []Offset {
+	return t.shiftsOver(Cell{}, t.rows*copiesV, t.cols*copiesH)
+}
+
+// shiftsOver is Shifts for the height x width area with top left corner top,
+// in the coordinates of the tile array.
+func (t *Pattern) shiftsOver(top Cell, height, width int) []Offset {
+	lo, hi := t.bounds()
+
+	// a shifted tile overlaps the area grown by margin on every side;
+	// the search goes through copies just outside the area too,
+	// since they can be the only way to reach one inside
+	overlaps := func(s Offset, margin int) bool {
+		return lo.Row+s.Row < top.Row+height+margin && hi.Row+s.Row >= top.Row-margin &&
+			lo.Col+s.Col < top.Col+width+margin && hi.Col+s.Col >= top.Col-margin
+	}
+	// the rules are bounded by checkRules, so -d can't overflow
+	margin := 0
+	for _, r := range t.diag.Rules {
+		for _, d := range []int{r.Row, r.Col} {
+			switch {
+			case d > margin:
+				margin = d
+			case d < -margin:
+				margin = -d
+			}
+		}
+	}
+
+	shifts := []Offset{{}}
+	seen := map[Offset]bool{{}: true}
+	queue := []Offset{{}}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for _, r := range t.diag.Rules {
+			next := Offset{s.Row + r.Row, s.Col + r.Col}
+			if seen[next] || !overlaps(next, margin) {
+				continue
+			}
+			seen[next] = true
+			queue = append(queue, next)
+			if overlaps(next, 0) {
+				shifts = append(shifts, next)
+			}
+		}
+	}
+	return shifts
+}
+
+// Unfold lays out copies of the tile cells of tile, shifted as by Shifts,
+// in a new array of copiesV x copiesH tile arrays. The original tile keeps
+// its place in the top left array and copies are clipped at the edges.
+// Positions no copy reaches are dead.
+func (t *Pattern) Unfold(tile [][]bool, copiesH, copiesV int) [][]bool {
+	grid := NewTile(t.rows*copiesV, t.cols*copiesH)
+	for _, s := range t.Shifts(copiesH, copiesV) {
+		for _, c := range t.cells {
+			r, col := c.Row+s.Row, c.Col+s.Col
+			if 0 <= r && r < grid.rows && 0 <= col && col < grid.cols {
+				grid.grid[r][col] = tile[c.Row][c.Col]
+			}
+		}
+	}
+	return grid.grid
+}
+
+// unfoldGrid is Unfold for multi-state grids.
+func (t *Pattern) unfoldGrid(grid Grid, copiesH, copiesV int) Grid {
+	plane := NewGrid(t.rows*copiesV, t.cols*copiesH)
+	for _, s := range t.Shifts(copiesH, copiesV) {
+		for _, c := range t.cells {
+			r, col := c.Row+s.Row, c.Col+s.Col
+			if 0 <= r && r < plane.rows && 0 <= col && col < plane.cols {
+				plane.cells[r][col] = grid.cells[c.Row][c.Col]
+			}
+		}
+	}
+	return plane
+}
+
+// Fold is the inverse of Unfold: it maps every position of grid back to
+// the tile cell it is a copy of and returns the tile. origin is where the
+// top left corner of the tile array is in grid; it may be outside grid.
+// Fold returns an error if two copies of one tile cell in grid differ,
+// i.e. grid does not repeat the way the rules say. Tile cells with no
+// copy in grid are dead.
+func (t *Pattern) Fold(grid [][]bool, origin Cell) ([][]bool, error) {
+	g, err := TileFrom(grid)
+	if err != nil {
+		return nil, fmt.Errorf("Fold: pattern: %w", err)
+	}
+
+	tile := NewTile(t.rows, t.cols)
+	from := make([]Cell, len(t.cells)) // first position in grid of each cell
+	seen := make([]bool, len(t.cells))
+	area := Cell{-origin.Row, -origin.Col}
+	for _, s := range t.shiftsOver(area, g.rows, g.cols) {
+		for i, c := range t.cells {
+			at := Cell{origin.Row + c.Row + s.Row, origin.Col + c.Col + s.Col}
+			if at.Row < 0 || at.Row >= g.rows || at.Col < 0 || at.Col >= g.cols {
+				continue
+			}
+			v := g.grid[at.Row][at.Col]
+			if !seen[i] {
+				seen[i], from[i] = true, at
+				tile.grid[c.Row][c.Col] = v
+			} else if tile.grid[c.Row][c.Col] != v {
+				return nil, fmt.Errorf("Fold: pattern: grid does not repeat: r:%v c:%v and r:%v c:%v are copies of id:%v but differ",
+					from[i].Row, from[i].Col, at.Row, at.Col, i+1)
+			}
+		}
+	}
+	return tile.grid, nil
+}
@@ -0,0 +1,38 @@
+package pattern
+
+// The states of Wireworld.
+const (
+	Empty uint8 = iota
+	Conductor
+	Head // electron head
+	Tail // electron tail
+)
+
+// EvolveWireworld is Evolve for Wireworld: an electron Head becomes a Tail,
+// a Tail becomes Conductor again, and a Conductor becomes a Head when one
+// or two of its neighbors are Heads. Empty cells stay empty. Neighbors are
+// counted in the pattern's neighborhood and, like Evolve, it fills in the
+// border of grid first, so electrons run across the seams of the tile.
+// The pattern's Rule is not used.
+func (t *Pattern) EvolveWireworld(grid, newGrid Grid) error {
+	if err := t.checkGrids("EvolveWireworld", grid, newGrid, int(Tail)+1); err != nil {
+		return err
+	}
+
+	t.fillGridBorder(grid.cells)
+	for _, c := range t.cells {
+		s := grid.cells[c.Row][c.Col]
+		switch s {
+		case Head:
+			s = Tail
+		case Tail:
+			s = Conductor
+		case Conductor:
+			if n := countState(grid.cells, c.Row, c.Col, t.offsets.at(c.Row), Head); n == 1 || n == 2 {
+				s = Head
+			}
+		}
+		newGrid.cells[c.Row][c.Col] = s
+	}
+	return nil
+}
@@ -2,16 +2,32 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/gif"
+	"io"
 	"log"
+	"math"
+	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/fidelcoria/tessellation/pattern"
+	"github.com/fidelcoria/tessellation/version"
 )
 
 const (
@@ -23,6 +39,10 @@ const (
 var on = color.RGBA{163, 73, 164, 255}          // purplish
 var off = color.RGBA{200, 191, 231, 255}        // light lila
 var background = color.RGBA{164, 149, 120, 255} // light brown
+var red = color.RGBA{196, 58, 58, 255}          // immigration species
+var blue = color.RGBA{58, 92, 196, 255}
+var refractory = color.RGBA{92, 170, 170, 255} // Brian's Brain, after firing
+var copper = color.RGBA{214, 160, 56, 255}     // Wireworld conductor
 
 var palette = color.Palette{
 	on,
@@ -30,121 +50,861 @@ var palette = color.Palette{
 	background,
 }
 
-// Circle is used as a mask shape to draw the GIF.
-type Circle struct {
-	P image.Point
-	R int
+// stateColors holds the color of each cell state: dead, then alive or the
+// live states of a multi-state game, which are also added to the palette
+var stateColors = []color.RGBA{off, on}
+
+// blend mixes c over bg with the given opacity.
+func blend(c, bg color.RGBA, alpha float64) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(b) + alpha*(float64(a)-float64(b)) + 0.5)
+	}
+	return color.RGBA{mix(c.R, bg.R), mix(c.G, bg.G), mix(c.B, bg.B), 255}
+}
+
+// Ellipse is used as a mask shape to draw the GIF.
+// Equal radii give a circle, the default for square cells.
+type Ellipse struct {
+	P      image.Point
+	RX, RY int
 }
 
-// ColorModel returns color.Model of Circle; implements Image interface.
-func (c *Circle) ColorModel() color.Model {
+// ColorModel returns color.Model of Ellipse; implements Image interface.
+func (e *Ellipse) ColorModel() color.Model {
 	return color.AlphaModel
 }
 
-// Bounds returns bounds of circle; implements Image interface.
-func (c *Circle) Bounds() image.Rectangle {
-	return image.Rect(c.P.X-c.R, c.P.Y-c.R, c.P.X+c.R, c.P.Y+c.R)
+// Bounds returns bounds of ellipse; implements Image interface.
+func (e *Ellipse) Bounds() image.Rectangle {
+	return image.Rect(e.P.X-e.RX, e.P.Y-e.RY, e.P.X+e.RX, e.P.Y+e.RY)
 }
 
-// At finds if (x, y) is in the circle or not.
-func (c *Circle) At(x, y int) color.Color {
-	xx, yy, rr := float64(x-c.P.X)+0.5, float64(y-c.P.Y)+0.5, float64(c.R)
-	if xx*xx+yy*yy < rr*rr {
+// At finds if (x, y) is in the ellipse or not.
+func (e *Ellipse) At(x, y int) color.Color {
+	xx, yy := float64(x-e.P.X)+0.5, float64(y-e.P.Y)+0.5
+	rx, ry := float64(e.RX), float64(e.RY)
+	// (xx/rx)^2 + (yy/ry)^2 < 1, without dividing
+	if xx*xx*ry*ry+yy*yy*rx*rx < rx*rx*ry*ry {
 		return color.Alpha{255} // opaque
 	}
 	return color.Alpha{0} // transparent
 }
 
+// cell size in pixels, cells need not be square (e.g. for LED matrices)
+var (
+	cellWidth  = flag.Int("cell-width", 10, "width of a cell in pixels")
+	cellHeight = flag.Int("cell-height", 10, "height of a cell in pixels")
+)
+
+// acceptance check of the final generation, for regression testing
+var (
+	expectFile = flag.String("expect", "", "tile CSV the final generation must match")
+	expectHash = flag.String("expect-hash", "", "hex hash (as printed on mismatch) the final generation must match")
+)
+
+// dead cell rendering, sparse patterns look (and compress) better with less lilac
+var (
+	hideDead  = flag.Bool("hide-dead", false, "leave dead cells as background instead of drawing a dot")
+	deadAlpha = flag.Float64("dead-alpha", 1, "opacity of dead dots over the background, from 0 to 1")
+)
+
+// combined grid files hold mask and seed together, see pattern.LoadCombined
+var (
+	gridFile  = flag.String("grid", "", "combined mask and seed grid file, replaces the mask and tile CSVs")
+	saveFinal = flag.String("save-final", "", "write the final generation as a combined grid file")
+)
+
+// unstyled exports, one pixel per tile cell
+var (
+	rawFrames = flag.String("raw-frames", "", "directory to also write each generation as a 1-bit image")
+	rawFormat = flag.String("raw-format", "pbm", "format of -raw-frames images: pbm or png")
+)
+
+// population sparkline under the cells, see popChart
+var (
+	chartKind   = flag.String("chart", "", "draw a chart strip under the animation: population")
+	chartHeight = flag.Int("chart-height", 30, "height of the -chart strip in pixels")
+)
+
+// post-processing of every frame to fit sharing platforms' dimensions
+var (
+	padTo      = flag.String("pad-to", "", "letterbox frames with background to WxH pixels, e.g. 1080x1080")
+	cropTo     = flag.String("crop-to", "", "crop frames to WxH pixels (applied before -pad-to)")
+	cropAnchor = flag.String("crop-anchor", "center", "part of the frame -crop-to keeps: center, top, bottom, left, right, top-left, top-right, bottom-left or bottom-right")
+)
+
+// padSize and cropSize are the parsed -pad-to and -crop-to, zero if unset
+var padSize, cropSize image.Point
+
+// onFrameError is the policy for frames that still fail to save after a retry
+var onFrameError = flag.String("on-frame-error", "abort", "when a frame can't be written or composed into the GIF: drop it, repeat the previous one, or abort")
+
+var showVersion = flag.Bool("version", false, "print version information and exit")
+
+// seeds and states as lists of cell ids, see readIDs
+var (
+	tileIDs = flag.String("tile-ids", "", "seed file listing live cell ids (one per line or a JSON array), replaces the tile")
+	saveIDs = flag.String("save-ids", "", "write the live cell ids of the final generation, one per line")
+)
+
+// step-through explanation of a single cell, see pattern.ExplainCell
+var (
+	explainAt  = flag.String("explain-cell", "", "explain the next state of the tile cell at r,c")
+	explainGen = flag.Int("explain-gen", 0, "generation in which to explain -explain-cell")
+)
+
+// per-generation statistics, see pattern.Stats
+var logStats = flag.Bool("stats", false, "log the births, deaths, survivors and population of every generation to stderr")
+
+// birth/death log, for driving something else from a run
+var events = flag.String("events", "", "write a CSV row for every birth and death in the tile: generation, id, row, col, birth or death")
+
+// how many generations to animate
+var frames = flag.Int("frames", 42, "number of generations to calculate after the seed, 0 renders just the seed")
+
+// stop when nothing is left to animate
+var earlyStop = flag.Bool("early-stop", true, "stop once the population dies out or stops changing; false always renders every generation")
+
+// birth/survival rule, see pattern.ParseRule
+var ruleString = flag.String("rule", "B3/S23", "birth/survival rulestring, e.g. B36/S23 for HighLife, B2/S345/4 for a Generations rule with 4 states, immigration, brian or wireworld")
+
+// game is a multi-state game, played on a pattern.Grid instead of a tile
+type game struct {
+	rule   string           // rulestring of the pattern
+	colors []color.RGBA     // colors of the states after dead
+	tokens map[string]uint8 // tile CSV tokens of the live states
+
+	// evolve computes the next generation of grid
+	evolve func(pat *pattern.Pattern, grid, next pattern.Grid) error
+}
+
+// games that -rule selects by name
+var games = map[string]game{
+	"immigration": {
+		rule:   "B3/S23",
+		colors: []color.RGBA{red, blue},
+		tokens: map[string]uint8{aliveToken: uint8(pattern.Red), "R": uint8(pattern.Red), "B": uint8(pattern.Blue)},
+		evolve: (*pattern.Pattern).EvolveImmigration,
+	},
+	"brian": {
+		rule:   pattern.BriansBrain.String(),
+		colors: []color.RGBA{on, refractory},
+		tokens: map[string]uint8{aliveToken: pattern.Firing, "F": pattern.Firing, "R": pattern.Refractory},
+		evolve: (*pattern.Pattern).EvolveGenerations,
+	},
+	"wireworld": {
+		rule:   "B/S", // not used
+		colors: []color.RGBA{copper, blue, red},
+		tokens: map[string]uint8{aliveToken: pattern.Conductor, "C": pattern.Conductor, "H": pattern.Head, "T": pattern.Tail},
+		evolve: (*pattern.Pattern).EvolveWireworld,
+	},
+}
+
+// flags that only work with two-state rules
+var twoStateFlags = []string{"expect", "expect-hash", "save-final", "save-ids", "raw-frames", "chart", "explain-cell", "stats", "on-frame-error", "noise", "mutation", "events"}
+
+// neighbors that count, see pattern.Neighborhood
+var neighborhood = flag.String("neighborhood", "moore", "cells that count as neighbors: moore (all 8), vonneumann (orthogonal 4) or hex (6, odd rows drawn half a cell to the right)")
+
+// transition table that replaces -rule, see pattern.LoadRuleTable
+var (
+	ruleTable       = flag.String("rule-table", "", "file of transitions 'state neighbors next', one per line, used instead of -rule")
+	ruleTableStrict = flag.Bool("rule-table-strict", false, "fail if -rule-table leaves out a transition instead of letting the cell be dead")
+)
+
+// what lies around the tile, see pattern.NewFinite
+var boundary = flag.String("boundary", "tessellate", "what surrounds the tile: tessellate (copies of it) or dead (nothing, the tile evolves as an island)")
+
+// noisy rules and mutations, see pattern.WithNoise and pattern.WithMutation
+var (
+	noise      = flag.Float64("noise", 1, "probability that each birth and death the rule calls for happens, below 1 for noisy Life")
+	randomSeed = flag.Int64("seed", 1, "seed of the random numbers -noise and -mutation draw, the same seed gives the same animation")
+	mutation   = flag.Float64("mutation", 0, "probability that each tile cell flips after every generation, 0 for none")
+)
+
+// maxDiffs limits how many differing cells are listed when -expect fails
+const maxDiffs = 10
+
 func main() {
-	maskData := readCSV(maskFile)
-	mask := make([][]bool, len(maskData))
-	for i, record := range maskData {
-		mask[i] = make([]bool, len(record))
-		for j, field := range record {
-			if field == "1" {
-				mask[i][j] = true
+	flag.Parse()
+	if *showVersion {
+		fmt.Println("tessellation", version.Get())
+		return
+	}
+
+	if *cellWidth < 2 || *cellHeight < 2 {
+		log.Fatalf("cell size must be at least 2x2 pixels, got %dx%d", *cellWidth, *cellHeight)
+	}
+	if *deadAlpha < 0 || *deadAlpha > 1 {
+		log.Fatalf("dead-alpha must be between 0 and 1, got %v", *deadAlpha)
+	}
+	if *onFrameError != "drop" && *onFrameError != "repeat" && *onFrameError != "abort" {
+		log.Fatalf("on-frame-error must be drop, repeat or abort, got %q", *onFrameError)
+	}
+	if *chartKind != "" && *chartKind != "population" {
+		log.Fatalf("chart must be population, got %q", *chartKind)
+	}
+	if *chartKind != "" && *chartHeight < 2 {
+		log.Fatalf("chart-height must be at least 2 pixels, got %d", *chartHeight)
+	}
+	if *frames < 0 {
+		log.Fatalf("frames must be at least 0, got %d", *frames)
+	}
+	if *rawFormat != "pbm" && *rawFormat != "png" {
+		log.Fatalf("raw-format must be pbm or png, got %q", *rawFormat)
+	}
+	if *explainAt != "" && (*noise != 1 || *mutation != 0) {
+		log.Fatal("-explain-cell explains the rule, which -noise and -mutation change; don't give them together")
+	}
+	if *boundary != "tessellate" && *boundary != "dead" {
+		log.Fatalf("boundary must be tessellate or dead, got %q", *boundary)
+	}
+	g, named := games[*ruleString]
+	rulestring := *ruleString
+	if named {
+		rulestring = g.rule
+	}
+	rule, err := pattern.ParseGenerationsRule(rulestring)
+	if err != nil {
+		log.Fatalf("rule: %v", err)
+	}
+	if !named && rule.States() > 2 {
+		g = game{tokens: map[string]uint8{aliveToken: 1}, evolve: (*pattern.Pattern).EvolveGenerations}
+	}
+	if g.evolve != nil {
+		flag.Visit(func(f *flag.Flag) {
+			for _, name := range twoStateFlags {
+				if f.Name == name {
+					log.Fatalf("-%s does not work with %s, which has more than two states", name, *ruleString)
+				}
+			}
+		})
+	}
+	nbhd, err := pattern.ParseNeighborhood(*neighborhood)
+	if err != nil {
+		log.Fatalf("neighborhood: %v", err)
+	}
+	if *ruleTable != "" {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "rule" {
+				log.Fatal("-rule-table replaces -rule, give only one")
 			}
+		})
+		rule, err = loadRuleTable(*ruleTable, nbhd)
+		if err != nil {
+			log.Fatalf("%s: %v", *ruleTable, err)
 		}
 	}
+	off = blend(off, background, *deadAlpha)
+	palette[1] = off
+	stateColors[0] = off
 
-	tileData := readCSV(tileFile)
-	aTile := make([][]bool, len(tileData))
-	for i, record := range tileData {
-		aTile[i] = make([]bool, len(record))
-		for j, field := range record {
-			if field == "X" {
-				aTile[i][j] = true
+	if g.evolve != nil && !named {
+		// dying cells fade from on to off
+		g.colors = []color.RGBA{on}
+		for k := 2; k < rule.States(); k++ {
+			g.colors = append(g.colors, blend(on, off, 1-float64(k-1)/float64(rule.States()-1)))
+		}
+	}
+	if g.evolve != nil {
+		stateColors = append(stateColors[:1], g.colors...)
+	next:
+		for _, c := range g.colors {
+			for _, p := range palette {
+				if p == c {
+					continue next
+				}
 			}
+			palette = append(palette, c)
+		}
+		if len(palette) > 256 {
+			log.Fatalf("rule: %s has too many states to draw", *ruleString)
+		}
+	}
+
+	var mask, aTile [][]bool
+	var gridLines []int // file line of each mask row, for -grid
+	if *gridFile != "" {
+		f, err := os.Open(*gridFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		mask, aTile, gridLines, err = pattern.LoadCombinedLines(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", *gridFile, err)
 		}
+	} else {
+		mask = readGrid(maskFile, "1")
+		aTile = readGrid(tileFile, "X")
 	}
 
 	// for bordering TODO read from file, maybe?
-	translations := []pattern.Offset{
-		{Row: -10, Col: -10},
-		{Row: -10, Col: 0},
-		{Row: -10, Col: 10},
-		{Row: 0, Col: -10},
-		{Row: 0, Col: 10},
-		{Row: 10, Col: -10},
-		{Row: 10, Col: 0},
-		{Row: 10, Col: 10},
-	}
-
-	tess, err := pattern.New(mask, translations)
+	// the tile repeats every 10 rows and every 10 columns
+	opts := []pattern.Option{pattern.WithGenerationsRule(rule), pattern.WithNeighborhood(nbhd)}
+	var tess *pattern.Pattern
+	if *boundary == "dead" {
+		tess, err = pattern.NewFinite(mask, opts...)
+	} else {
+		tess, err = pattern.NewLattice(mask, pattern.Offset{Row: 10}, pattern.Offset{Col: 10}, opts...)
+	}
+	if err != nil && *gridFile != "" {
+		err = fmt.Errorf("%s: %w", *gridFile, pattern.LocateCombined(err, gridLines))
+	}
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
 
-	// these additional translations are used to tile the entire GIF frame
-	translations = append(translations,
-		pattern.Offset{Row: 20, Col: -10},
-		pattern.Offset{Row: 20, Col: 0},
-		pattern.Offset{Row: 20, Col: 10},
-		pattern.Offset{Row: 20, Col: 20},
+	if *tileIDs != "" {
+		ids, err := readIDs(*tileIDs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if aTile, err = pattern.TileFromIDs(tess, ids); err != nil {
+			log.Fatalf("%s: %v", *tileIDs, err)
+		}
+	}
+	seed, err := pattern.TileFrom(aTile)
+	if err == nil {
+		err = tess.CheckTile(seed.Grid())
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repH, repV := 2, 2
+	if *boundary == "dead" {
+		repH, repV = 1, 1 // there are no copies to draw
+	}
+	if err := checkFrameSize(tess, repH, repV); err != nil {
+		log.Fatal(err)
+	}
+	if err := setupPostProcess(frameSize(tess, repH, repV)); err != nil {
+		log.Fatal(err)
+	}
 
-		pattern.Offset{Row: -10, Col: 20},
-		pattern.Offset{Row: 0, Col: 20},
-		pattern.Offset{Row: 10, Col: 20},
-	)
 	// number of frames to calculate (0.gif not included)
-	nFrames := 42 // found by trial and error...
+	nFrames := *frames
+	if *explainAt != "" && nFrames == 0 {
+		log.Fatal("-explain-cell explains a step, which -frames 0 doesn't take")
+	}
+	if *explainAt != "" && (*explainGen < 0 || *explainGen >= nFrames) {
+		log.Fatalf("explain-gen must be from 0 to %d, got %d", nFrames-1, *explainGen)
+	}
+
+	// Ctrl-C stops the simulation between generations and the frames so far
+	// are still composed; a second one kills the process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if g.evolve != nil {
+		// the tile CSV can hold every live state, the other seeds only one
+		grid := pattern.GridOf(seed)
+		if *gridFile == "" && *tileIDs == "" {
+			if grid, err = pattern.GridFrom(readStates(tileFile, g.tokens)); err != nil {
+				log.Fatal(err)
+			}
+		}
+		playStates(ctx, tess, grid, g.evolve, repH, repV, nFrames)
+		printArtifacts()
+		return
+	}
+
+	final := play(ctx, tess, seed, repH, repV, nFrames, rand.New(rand.NewSource(*randomSeed)))
 
-	play(tess, aTile, translations, 2, 2, nFrames)
+	if *saveFinal != "" {
+		if err := saveCombined(*saveFinal, tess, final); err != nil {
+			log.Fatal(err)
+		}
+		addArtifact("grid", fmt.Sprintf("%dx%d cells", tess.Cols(), tess.Rows()), *saveFinal)
+	}
+
+	if *saveIDs != "" {
+		if err := writeIDs(*saveIDs, tess.LiveIDs(final.Grid())); err != nil {
+			log.Fatal(err)
+		}
+		addArtifact("cell ids", fmt.Sprintf("%d live cells", len(tess.LiveIDs(final.Grid()))), *saveIDs)
+	}
+
+	printArtifacts()
+
+	if err := checkExpected(tess, final); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// explain prints the explanation of the -explain-cell cell in tile.
+func explain(pat *pattern.Pattern, tile pattern.Tile) {
+	var c pattern.Cell
+	if _, err := fmt.Sscanf(*explainAt, "%d,%d", &c.Row, &c.Col); err != nil {
+		log.Fatalf("explain-cell: want r,c, got %q", *explainAt)
+	}
+	e, err := pat.ExplainCell(tile.Grid(), c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("generation %d: %v", *explainGen, e)
+}
+
+// artifact describes a file, or a group of files, written by the run.
+type artifact struct {
+	path, kind string
+	size       int64  // total size in bytes
+	detail     string // dimensions, frame counts, ...
+}
+
+// artifacts lists everything the run wrote, in order, for the exit summary.
+var artifacts []artifact
+
+// addArtifact records the files in paths as one artifact; a group is shown by its directory.
+func addArtifact(kind, detail string, paths ...string) {
+	a := artifact{path: paths[0], kind: kind, detail: detail}
+	if len(paths) > 1 {
+		a.path = filepath.Dir(paths[0]) + string(filepath.Separator)
+	}
+	for _, path := range paths {
+		if fi, err := os.Stat(path); err == nil {
+			a.size += fi.Size()
+		}
+	}
+	artifacts = append(artifacts, a)
+}
+
+// printArtifacts writes the summary table of artifacts to stderr.
+func printArtifacts() {
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "artifact\ttype\tsize\tdetails")
+	for _, a := range artifacts {
+		fmt.Fprintf(w, "%s\t%s\t%d B\t%s\n", a.path, a.kind, a.size, a.detail)
+	}
+	w.Flush()
+}
+
+// unique returns names without repeats, keeping the first occurrence.
+func unique(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var u []string
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			u = append(u, name)
+		}
+	}
+	return u
+}
+
+// checkExpected compares the final generation against -expect and -expect-hash.
+// Only cells that are part of the tile are compared.
+func checkExpected(pat *pattern.Pattern, final pattern.Tile) error {
+	if *expectFile != "" {
+		want := readGrid(*expectFile, "X")
+		if len(want) == 0 {
+			return fmt.Errorf("expect: %s is empty, tile is %dx%d", *expectFile, pat.Rows(), pat.Cols())
+		}
+		if len(want) != pat.Rows() || len(want[0]) != pat.Cols() {
+			return fmt.Errorf("expect: %s is %dx%d, tile is %dx%d",
+				*expectFile, len(want), len(want[0]), pat.Rows(), pat.Cols())
+		}
+
+		cells := pat.Diff(final.Grid(), want)
+		var diffs []string
+		for _, c := range cells {
+			if len(diffs) == maxDiffs {
+				break
+			}
+			id, _ := pat.Canonicalize(c.Row, c.Col)
+			diffs = append(diffs, fmt.Sprintf("  id:%v r:%v c:%v got %v want %v",
+				id, c.Row, c.Col, final.Get(c.Row, c.Col), want[c.Row][c.Col]))
+		}
+		if len(cells) > 0 {
+			return fmt.Errorf("expect: final generation differs from %s in %d cells:\n%s",
+				*expectFile, len(cells), strings.Join(diffs, "\n"))
+		}
+	}
+
+	if *expectHash != "" {
+		if got := stateHash(pat, final); !strings.EqualFold(got, *expectHash) {
+			return fmt.Errorf("expect-hash: final generation hash is %s, want %s", got, *expectHash)
+		}
+	}
+
+	return nil
+}
+
+// stateHash is the hex SHA-256 of the tile cells' states in id order ('1' alive, '0' dead).
+func stateHash(pat *pattern.Pattern, tile pattern.Tile) string {
+	h := sha256.New()
+	pat.EachCell(func(_ int, c pattern.Cell) bool {
+		if tile.Get(c.Row, c.Col) {
+			h.Write([]byte{'1'})
+		} else {
+			h.Write([]byte{'0'})
+		}
+		return true
+	})
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // play runs the simulation and creates the GIFs
+// ctx interrupts the simulation, see pattern.Simulation.RunContext
 // pat has information about the tile pattern
 // aTile is the original (first generation) tile
-// shifts indicate how to shift tile to tessellate the GIF frame
-// nFrames is the number of generations to calculate
-func play(pat *pattern.Pattern, aTile [][]bool, shifts []pattern.Offset, repH, repV int, nFrames int) {
+// repH and repV are how many tiles wide and high the GIF frame is
+// nFrames is the number of generations to calculate, 0 renders just the seed
+// random draws the numbers of -noise and -mutation
+// The final generation is returned.
+func play(ctx context.Context, pat *pattern.Pattern, aTile pattern.Tile, repH, repV int, nFrames int, random *rand.Rand) pattern.Tile {
+
+	// how to shift tile to tessellate the GIF frame
+	shifts := pat.Shifts(repH, repV)
+
+	sim, err := pattern.NewSimulation(pat, aTile,
+		pattern.WithNoise(pattern.Probabilities{Birth: *noise, Death: *noise}, random),
+		pattern.WithMutation(*mutation, random))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	names := make([]string, 0, nFrames+1)
+	var incidents []string
+
+	var chart *popChart
+	if *chartKind == "population" {
+		chart = &popChart{gens: nFrames}
+	}
+
+	// the hooks run in this order with every generation, see pattern.Hook
+	if *logStats {
+		sim.OnGeneration(func(gen int, _ pattern.Tile) error {
+			if gen > 0 {
+				st := sim.Stats()
+				mutations := ""
+				if *mutation > 0 {
+					mutations = fmt.Sprintf(", %d mutations", st.Mutations)
+				}
+				fmt.Fprintf(os.Stderr, "generation %d: %d births, %d deaths, %d survivors%s, population %d\n",
+					gen, st.Births, st.Deaths, st.Survivors, mutations, st.Population)
+			}
+			return nil
+		})
+	}
+
+	// save a GIF frame, retrying once, and apply -on-frame-error
+	// (the frames directory must already exist)
+	sim.OnGeneration(func(gen int, tile pattern.Tile) error {
+		if chart != nil {
+			chart.add(pat.Population(tile.Grid()))
+		}
+
+		name := fmt.Sprintf("frames/%d.gif", gen)
+		err := saveGIFFrame(pat, shifts, repH, repV, tile, chart, name)
+		if err != nil {
+			err = saveGIFFrame(pat, shifts, repH, repV, tile, chart, name)
+		}
+		if err == nil {
+			names = append(names, name)
+			return nil
+		}
+
+		switch *onFrameError {
+		case "drop":
+			incidents = append(incidents, fmt.Sprintf("generation %d dropped: %v", gen, err))
+		case "repeat":
+			if len(names) == 0 {
+				incidents = append(incidents, fmt.Sprintf("generation %d dropped, no previous frame to repeat: %v", gen, err))
+				break
+			}
+			names = append(names, names[len(names)-1])
+			incidents = append(incidents, fmt.Sprintf("generation %d replaced by previous frame: %v", gen, err))
+		default:
+			return err
+		}
+		return nil
+	})
+
+	sim.OnGeneration(func(gen int, tile pattern.Tile) error {
+		saveRawFrame(pat, tile, gen)
+		return nil
+	})
 
-	bTile := make([][]bool, len(aTile))
-	for i := range bTile {
-		bTile[i] = make([]bool, len(aTile[0]))
+	// one row per tile cell that changed, in id order; this compares the
+	// tiles, so the flips of -mutation are births and deaths too
+	var eventLog *csv.Writer
+	var eventFile *os.File
+	births, deaths := 0, 0
+	if *events != "" {
+		if eventFile, err = os.Create(*events); err != nil {
+			log.Fatal(err)
+		}
+		eventLog = csv.NewWriter(eventFile)
+		eventLog.Write([]string{"generation", "id", "row", "col", "event"})
+		prev := pattern.NewTile(pat.Rows(), pat.Cols())
+		sim.OnGeneration(func(gen int, tile pattern.Tile) error {
+			if gen > 0 {
+				pat.EachCell(func(id int, c pattern.Cell) bool {
+					was, is := prev.Get(c.Row, c.Col), tile.Get(c.Row, c.Col)
+					if was == is {
+						return true
+					}
+					event := "death"
+					if is {
+						event, births = "birth", births+1
+					} else {
+						deaths++
+					}
+					eventLog.Write([]string{strconv.Itoa(gen), strconv.Itoa(id), strconv.Itoa(c.Row), strconv.Itoa(c.Col), event})
+					return true
+				})
+			}
+			return prev.CopyFrom(tile)
+		})
 	}
 
-	names := make([]string, nFrames+1)
+	explained := false
+	if *explainAt != "" {
+		sim.OnGeneration(func(gen int, tile pattern.Tile) error {
+			if gen == *explainGen {
+				explain(pat, tile)
+				explained = true
+			}
+			return nil
+		})
+	}
 
-	// save initial frame (the frames directory must already exist)
-	names[0] = "frames/0.gif"
-	saveGIFFrame(pat, shifts, repH, repV, aTile, names[0])
+	// runs last, so the generation it stops at still gets its frame;
+	// mutations can bring any tile back to life, and with noise a
+	// generation can look still only because every change was held back
+	if *earlyStop && *mutation == 0 && *noise == 1 {
+		sim.OnGeneration(func(gen int, _ pattern.Tile) error {
+			if gen == 0 {
+				return nil
+			}
+			switch st := sim.Stats(); {
+			case st.Population == 0:
+				fmt.Fprintf(os.Stderr, "population died out in generation %d, stopping early\n", gen)
+			case st.Births+st.Deaths == 0:
+				fmt.Fprintf(os.Stderr, "generation %d is a still life, stopping early\n", gen)
+			default:
+				return nil
+			}
+			return pattern.ErrStop
+		})
+	}
 
-	for i, j := 1, 2; j <= nFrames; i, j = i+2, j+2 {
-		// the tile is evolved twice each iteration
-		// this avoids having to allocate new arrays
+	err = sim.RunContext(ctx, nFrames)
+	if err != nil && err == ctx.Err() {
+		fmt.Fprintf(os.Stderr, "interrupted after generation %d, composing the frames so far\n", sim.Generation())
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	if eventLog != nil {
+		eventLog.Flush()
+		if err := eventLog.Error(); err != nil {
+			log.Fatal(err)
+		}
+		if err := eventFile.Close(); err != nil {
+			log.Fatal(err)
+		}
+		addArtifact("events", fmt.Sprintf("%d births, %d deaths", births, deaths), *events)
+	}
+	if *explainAt != "" && !explained {
+		fmt.Fprintf(os.Stderr, "explain-cell: the run ended in generation %d, before -explain-gen %d\n", sim.Generation(), *explainGen)
+	}
 
-		pat.Evolve(aTile, bTile)
-		names[i] = fmt.Sprintf("frames/%d.gif", i)
-		saveGIFFrame(pat, shifts, repH, repV, bTile, names[i])
+	composed, composeIncidents, err := composeGIF(names, "evolution.gif")
+	incidents = append(incidents, composeIncidents...)
+	if len(incidents) > 0 {
+		fmt.Fprintf(os.Stderr, "%d frame(s) could not be written:\n", len(incidents))
+		for _, incident := range incidents {
+			fmt.Fprintln(os.Stderr, " ", incident)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		pat.Evolve(bTile, aTile)
-		names[j] = fmt.Sprintf("frames/%d.gif", j)
-		saveGIFFrame(pat, shifts, repH, repV, aTile, names[j])
+	size := postProcessedSize(frameSize(pat, repH, repV))
+	addArtifact("gif frames", fmt.Sprintf("%d files, %dx%d", len(unique(names)), size.X, size.Y), unique(names)...)
+	addArtifact("animated gif", fmt.Sprintf("%dx%d, %d frames", size.X, size.Y, composed), "evolution.gif")
+	if *rawFrames != "" {
+		raws := make([]string, sim.Generation()+1)
+		for gen := range raws {
+			raws[gen] = filepath.Join(*rawFrames, fmt.Sprintf("%d.%s", gen, *rawFormat))
+		}
+		addArtifact("raw frames", fmt.Sprintf("%d files, %dx%d", len(raws), pat.Cols(), pat.Rows()), raws...)
 	}
 
-	composeGIF(names, "evolution.gif")
+	return sim.Current()
+}
+
+// playStates is play for multi-state games: it renders the evolution of
+// grid under evolve with a color for each state. Only the GIF is written.
+func playStates(ctx context.Context, pat *pattern.Pattern, grid pattern.Grid, evolve func(*pattern.Pattern, pattern.Grid, pattern.Grid) error, repH, repV int, nFrames int) {
+	shifts := pat.Shifts(repH, repV)
+	next := pattern.NewGrid(grid.Rows(), grid.Cols())
+
+	var names []string
+	stopping := false
+	for gen := 0; ; gen++ {
+		name := fmt.Sprintf("frames/%d.gif", gen)
+		err := saveStatesFrame(pat, shifts, repH, repV, grid.Get, nil, name)
+		if err != nil {
+			err = saveStatesFrame(pat, shifts, repH, repV, grid.Get, nil, name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		names = append(names, name)
+
+		if gen == nFrames || stopping {
+			break
+		}
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "interrupted after generation %d, composing the frames so far\n", gen)
+			break
+		}
+		if err := evolve(pat, grid, next); err != nil {
+			log.Fatal(err)
+		}
+		grid, next = next, grid
+
+		if *earlyStop {
+			population, changed := 0, false
+			pat.EachCell(func(_ int, c pattern.Cell) bool {
+				if grid.Get(c.Row, c.Col) != 0 {
+					population++
+				}
+				changed = changed || grid.Get(c.Row, c.Col) != next.Get(c.Row, c.Col)
+				return true
+			})
+			// the generation it stops at still gets its frame
+			switch {
+			case population == 0:
+				fmt.Fprintf(os.Stderr, "population died out in generation %d, stopping early\n", gen+1)
+				stopping = true
+			case !changed:
+				fmt.Fprintf(os.Stderr, "generation %d is a still life, stopping early\n", gen+1)
+				stopping = true
+			}
+		}
+	}
+
+	// -on-frame-error is a two-state flag, so a bad frame always aborts here
+	composed, _, err := composeGIF(names, "evolution.gif")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	size := postProcessedSize(frameSize(pat, repH, repV))
+	addArtifact("gif frames", fmt.Sprintf("%d files, %dx%d", len(names), size.X, size.Y), names...)
+	addArtifact("animated gif", fmt.Sprintf("%dx%d, %d frames", size.X, size.Y, composed), "evolution.gif")
+}
+
+// readIDs reads cell ids, either a JSON array or one id per line.
+func readIDs(name string) ([]int, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	if text := strings.TrimSpace(string(data)); strings.HasPrefix(text, "[") {
+		if err := json.Unmarshal([]byte(text), &ids); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return ids, nil
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		id, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %q is not a cell id", name, i+1, line)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// writeIDs writes cell ids one per line.
+func writeIDs(name string, ids []int) error {
+	var b strings.Builder
+	for _, id := range ids {
+		fmt.Fprintln(&b, id)
+	}
+	return os.WriteFile(name, []byte(b.String()), 0600)
+}
+
+// readGrid reads a CSV into a grid that is true wherever the field is token.
+func readGrid(name, token string) [][]bool {
+	records := readCSV(name)
+	grid := make([][]bool, len(records))
+	for i, record := range records {
+		grid[i] = make([]bool, len(record))
+		for j, field := range record {
+			if field == token {
+				grid[i][j] = true
+			}
+		}
+	}
+	return grid
+}
+
+// aliveToken marks a live cell in the tile CSV, as in pattern.LoadCombined
+const aliveToken = "X"
+
+// readStates reads a CSV into a grid of the states of tokens, 0 elsewhere.
+func readStates(name string, tokens map[string]uint8) [][]uint8 {
+	records := readCSV(name)
+	grid := make([][]uint8, len(records))
+	for i, record := range records {
+		grid[i] = make([]uint8, len(record))
+		for j, field := range record {
+			grid[i][j] = tokens[field]
+		}
+	}
+	return grid
+}
+
+// loadRuleTable reads a -rule-table file as a two-state rule.
+func loadRuleTable(name string, nbhd pattern.Neighborhood) (pattern.GenerationsRule, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return pattern.GenerationsRule{}, err
+	}
+	defer f.Close()
+
+	var table pattern.Rule
+	if *ruleTableStrict {
+		table, err = pattern.LoadRuleTableStrict(f, nbhd.Size(1))
+	} else {
+		table, err = pattern.LoadRuleTable(f)
+	}
+	if err != nil {
+		return pattern.GenerationsRule{}, err
+	}
+	return pattern.NewGenerationsRule(table, 2)
+}
+
+// saveCombined writes tile as a plain text combined grid.
+func saveCombined(name string, pat *pattern.Pattern, tile pattern.Tile) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := pat.WriteCombined(f, tile.Grid()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
 // readCSV wraps boiler plate code for reading a CSV.
@@ -168,80 +928,478 @@ func readCSV(name string) [][]string {
 
 // saveGIFFrame saves a GIF of the tile passed.
 // pat has information about the tile pattern
-// shifts are offsets for tiling the GIF frame
+// shifts are offsets for tiling the GIF frame, including the origin
 // repH, for size of GIF, counts how many times to repeat horizontally
 // repV, for size of GIF, counts how many times to repeat vertically
 // tile contains shape of pattern
+// chart, if not nil, is drawn in a strip under the cells
 // name is name of output GIF
-func saveGIFFrame(pat *pattern.Pattern, shifts []pattern.Offset, repH, repV int, tile [][]bool, name string) {
+func saveGIFFrame(pat *pattern.Pattern, shifts []pattern.Offset, repH, repV int, tile pattern.Tile, chart *popChart, name string) error {
+	state := func(r, c int) uint8 {
+		if tile.Get(r, c) {
+			return 1
+		}
+		return 0
+	}
+	return saveStatesFrame(pat, shifts, repH, repV, state, chart, name)
+}
+
+// saveStatesFrame is saveGIFFrame for cells in any state, drawn in the
+// colors of stateColors.
+func saveStatesFrame(pat *pattern.Pattern, shifts []pattern.Offset, repH, repV int, state func(r, c int) uint8, chart *popChart, name string) error {
 	// create masks for painting cells
-	// these are colored solid and masked with a circle
-	onSrc := &image.Uniform{on}
-	offSrc := &image.Uniform{off}
+	// these are colored solid and masked with an ellipse
+	srcs := make([]*image.Uniform, len(stateColors))
+	for i, c := range stateColors {
+		srcs[i] = &image.Uniform{c}
+	}
 
-	// each cell (dot) is in a square of size squarePix
-	squarePix := 10
+	// each cell (dot) is in a rectangle of size cellW x cellH
+	cellW, cellH := *cellWidth, *cellHeight
 
 	// I am visualizing the grid per the docs, so x=cols and y=rows
-	// each cell is getting a 10x10 square
-	img := image.NewPaletted(image.Rect(0, 0, squarePix*pat.Cols()*repH, squarePix*pat.Rows()*repV), palette)
+	img := image.NewPaletted(image.Rectangle{Max: frameSize(pat, repH, repV)}, palette)
 	// set background color
 	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.ZP, draw.Src)
 
-	shifts = append(shifts, pattern.Offset{Row: 0, Col: 0})
-
-	for _, cell := range pat.Cells {
+	pat.EachCell(func(_ int, cell pattern.Cell) bool {
 		for _, rule := range shifts {
 			offsetCol, offsetRow := cell.Col+rule.Col, cell.Row+rule.Row
 
 			cellRegion := image.Rect(
-				offsetCol*squarePix, offsetRow*squarePix,
-				offsetCol*squarePix+squarePix, offsetRow*squarePix+squarePix,
+				offsetCol*cellW, offsetRow*cellH,
+				offsetCol*cellW+cellW, offsetRow*cellH+cellH,
 			)
+			if offsetRow%2 != 0 && pat.Neighborhood() == pattern.Hex {
+				// odd rows sit between the cells of the rows around them
+				cellRegion = cellRegion.Add(image.Pt(cellW/2, 0))
+			}
 
-			var src *image.Uniform
-
-			if tile[cell.Row][cell.Col] {
-				src = onSrc
-			} else {
-				src = offSrc
+			s := state(cell.Row, cell.Col)
+			if s == 0 && *hideDead {
+				continue // leave the background showing
 			}
+			src := srcs[s]
 
-			// 4 is one less than 5, the radius of the square
-			dot := &Circle{R: 4} // center doesn't matter since shape gets aligned to cellRegion
+			// radii are one less than half the cell, e.g. 4 for a 10x10 square,
+			// but at least 1 so that 2 and 3 pixel cells still get a dot
+			rx, ry := cellW/2-1, cellH/2-1
+			if rx < 1 {
+				rx = 1
+			}
+			if ry < 1 {
+				ry = 1
+			}
+			dot := &Ellipse{RX: rx, RY: ry} // center doesn't matter since shape gets aligned to cellRegion
 			draw.DrawMask(img, cellRegion,
 				src, image.ZP,
 				dot, dot.Bounds().Min.Add(image.Point{-1, -1}), // shift by -1,-1 to center dots
 				draw.Over,
 			)
 		}
+		return true
+	})
+
+	if chart != nil {
+		chart.draw(img, image.Rect(0, cellH*pat.Rows()*repV, img.Bounds().Dx(), img.Bounds().Dy()))
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := gif.Encode(f, postProcess(img), nil); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return f.Close()
+}
+
+// Limits on frame dimensions, checked before any frame is allocated.
+// GIF stores dimensions in 16 bits.
+const (
+	maxFrameSide   = 1<<16 - 1
+	maxFramePixels = 1 << 26
+)
+
+// checkFrameSize makes sure frameSize won't overflow and is within the limits.
+func checkFrameSize(pat *pattern.Pattern, repH, repV int) error {
+	w, err := checkedMul(*cellWidth, pat.Cols(), repH)
+	if err == nil && pat.Neighborhood() == pattern.Hex {
+		w, err = checkedAdd(w, *cellWidth/2)
+	}
+	if err != nil {
+		return fmt.Errorf("frame width: %v", err)
+	}
+	h, err := checkedMul(*cellHeight, pat.Rows(), repV)
+	if err == nil && *chartKind != "" {
+		h, err = checkedAdd(h, *chartHeight)
+	}
+	if err != nil {
+		return fmt.Errorf("frame height: %v", err)
+	}
+	return checkDims(w, h)
+}
+
+// checkDims checks frame dimensions against maxFrameSide and maxFramePixels.
+func checkDims(w, h int) error {
+	if w > maxFrameSide || h > maxFrameSide {
+		return fmt.Errorf("frame of %dx%d pixels is larger than %dx%d", w, h, maxFrameSide, maxFrameSide)
+	}
+	if px, err := checkedMul(w, h); err != nil || px > maxFramePixels {
+		return fmt.Errorf("frame of %dx%d pixels has more than %d pixels", w, h, maxFramePixels)
+	}
+	return nil
+}
+
+// checkedMul multiplies non-negative factors, failing instead of overflowing.
+func checkedMul(factors ...int) (int, error) {
+	p := 1
+	for _, f := range factors {
+		if f < 0 {
+			return 0, fmt.Errorf("negative size %d", f)
+		}
+		if f != 0 && p > math.MaxInt32/f {
+			return 0, fmt.Errorf("size overflows")
+		}
+		p *= f
+	}
+	return p, nil
+}
+
+// checkedAdd adds non-negative sizes, failing instead of overflowing.
+func checkedAdd(a, b int) (int, error) {
+	if a < 0 || b < 0 {
+		return 0, fmt.Errorf("negative size")
+	}
+	if a > math.MaxInt32-b {
+		return 0, fmt.Errorf("size overflows")
+	}
+	return a + b, nil
+}
+
+// frameSize is the size in pixels of a GIF frame before post-processing.
+func frameSize(pat *pattern.Pattern, repH, repV int) image.Point {
+	size := image.Pt(*cellWidth*pat.Cols()*repH, *cellHeight*pat.Rows()*repV)
+	if pat.Neighborhood() == pattern.Hex {
+		size.X += *cellWidth / 2 // room for the shifted odd rows
+	}
+	if *chartKind != "" {
+		size.Y += *chartHeight
+	}
+	return size
+}
+
+// popChart is a population sparkline that grows as frames are rendered.
+// It is drawn while simulating (no second pass): the x axis spans all gens
+// generations up front and the y axis is scaled to the highest population
+// so far, so the curve can rescale as the run goes on.
+type popChart struct {
+	gens int
+	pops []int
+	max  int
+}
+
+// add appends the population of the next generation.
+func (c *popChart) add(pop int) {
+	c.pops = append(c.pops, pop)
+	if pop > c.max {
+		c.max = pop
+	}
+}
+
+// draw plots the populations so far inside r with a cursor at the latest one.
+func (c *popChart) draw(img *image.Paletted, r image.Rectangle) {
+	// tessellated copies can spill past the cells area, clear the strip
+	draw.Draw(img, r, &image.Uniform{background}, image.ZP, draw.Src)
+
+	r = r.Inset(1)
+	if r.Empty() || len(c.pops) == 0 {
+		return
+	}
+
+	x := func(gen int) int {
+		if c.gens == 0 {
+			return r.Min.X
+		}
+		return r.Min.X + gen*(r.Dx()-1)/c.gens
+	}
+	y := func(pop int) int {
+		if c.max == 0 {
+			return r.Max.Y - 1
+		}
+		return r.Max.Y - 1 - pop*(r.Dy()-1)/c.max
+	}
+
+	// cursor at the current generation
+	cur := x(len(c.pops) - 1)
+	draw.Draw(img, image.Rect(cur, r.Min.Y, cur+1, r.Max.Y), &image.Uniform{off}, image.ZP, draw.Src)
+
+	// connect consecutive points with vertical runs so steep changes stay visible
+	for gen, pop := range c.pops {
+		x0, y0, y1 := x(gen), y(pop), y(pop)
+		if gen > 0 {
+			y0 = y(c.pops[gen-1])
+		}
+		if y0 > y1 {
+			y0, y1 = y1, y0
+		}
+		draw.Draw(img, image.Rect(x0, y0, x0+1, y1+1), &image.Uniform{on}, image.ZP, draw.Src)
+	}
+}
+
+// setupPostProcess parses and validates -crop-to and -pad-to for frames of the given size.
+func setupPostProcess(frame image.Point) error {
+	var err error
+	if cropSize, err = parseSize(*cropTo); err != nil {
+		return fmt.Errorf("crop-to: %v", err)
+	}
+	if padSize, err = parseSize(*padTo); err != nil {
+		return fmt.Errorf("pad-to: %v", err)
+	}
+
+	if cropSize != (image.Point{}) {
+		if cropSize.X > frame.X || cropSize.Y > frame.Y {
+			return fmt.Errorf("crop-to: %dx%d is larger than the %dx%d frame", cropSize.X, cropSize.Y, frame.X, frame.Y)
+		}
+		if _, ok := anchors[*cropAnchor]; !ok {
+			return fmt.Errorf("crop-anchor: unknown anchor %q", *cropAnchor)
+		}
+		frame = cropSize
+	}
+	if padSize != (image.Point{}) {
+		if padSize.X < frame.X || padSize.Y < frame.Y {
+			return fmt.Errorf("pad-to: %dx%d is smaller than the %dx%d frame", padSize.X, padSize.Y, frame.X, frame.Y)
+		}
+		if err := checkDims(padSize.X, padSize.Y); err != nil {
+			return fmt.Errorf("pad-to: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// postProcessedSize is the size of a frame of the given size after postProcess.
+func postProcessedSize(frame image.Point) image.Point {
+	if cropSize != (image.Point{}) {
+		frame = cropSize
+	}
+	if padSize != (image.Point{}) {
+		frame = padSize
+	}
+	return frame
+}
+
+// parseSize parses "WxH" into a point, the empty string gives the zero point.
+func parseSize(s string) (image.Point, error) {
+	if s == "" {
+		return image.Point{}, nil
+	}
+	var p image.Point
+	if _, err := fmt.Sscanf(s, "%dx%d", &p.X, &p.Y); err != nil || p.X <= 0 || p.Y <= 0 {
+		return image.Point{}, fmt.Errorf("want positive WxH, got %q", s)
+	}
+	return p, nil
+}
+
+// anchors maps -crop-anchor values to the fraction of the spare width and
+// height (in halves) that is cut from the left and top.
+var anchors = map[string]image.Point{
+	"top-left": {0, 0}, "top": {1, 0}, "top-right": {2, 0},
+	"left": {0, 1}, "center": {1, 1}, "right": {2, 1},
+	"bottom-left": {0, 2}, "bottom": {1, 2}, "bottom-right": {2, 2},
+}
+
+// postProcess applies -crop-to and then -pad-to to a frame.
+// The frame is returned as is when neither is set.
+func postProcess(img *image.Paletted) *image.Paletted {
+	if cropSize != (image.Point{}) {
+		spare := img.Bounds().Size().Sub(cropSize)
+		a := anchors[*cropAnchor]
+		origin := image.Pt(spare.X*a.X/2, spare.Y*a.Y/2)
+
+		cropped := image.NewPaletted(image.Rectangle{Max: cropSize}, img.Palette)
+		draw.Draw(cropped, cropped.Bounds(), img, origin, draw.Src)
+		img = cropped
+	}
+
+	if padSize != (image.Point{}) {
+		spare := padSize.Sub(img.Bounds().Size())
+		origin := image.Pt(spare.X/2, spare.Y/2)
+
+		padded := image.NewPaletted(image.Rectangle{Max: padSize}, img.Palette)
+		draw.Draw(padded, padded.Bounds(), &image.Uniform{background}, image.ZP, draw.Src)
+		draw.Draw(padded, image.Rectangle{origin, origin.Add(img.Bounds().Size())}, img, image.ZP, draw.Src)
+		img = padded
+	}
+
+	return img
+}
+
+// saveRawFrame writes generation gen to the -raw-frames directory, if set.
+// The image is Cols() wide and Rows() high with live tile cells black.
+func saveRawFrame(pat *pattern.Pattern, tile pattern.Tile, gen int) {
+	if *rawFrames == "" {
+		return
+	}
+
+	name := filepath.Join(*rawFrames, fmt.Sprintf("%d.%s", gen, *rawFormat))
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Fatal(err)
 	}
+	defer f.Close()
 
-	f, _ := os.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0600)
-	defer f.Close() // why defer instead of closing after encoding
-	gif.Encode(f, img, nil)
+	if *rawFormat == "png" {
+		err = pat.WritePNG(f, tile.Grid())
+	} else {
+		err = pat.WritePBM(f, tile.Grid())
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
 }
 
 // composeGIF composes a group of GIF images into a single one.
 // frames is a slice with the names of the GIFs to compose
 // name is the name of the final GIF
+// It returns the number of frames in the GIF and, as for saving them, the
+// frames -on-frame-error dropped or repeated, see writeGIF.
 // credits: http://tech.nitoyon.com/en/blog/2016/01/07/go-animated-gif-gen/
 // TODO: there's a better way... only draw the parts that have changed
-//			that would require decoupling play, saveGIFFrame and composeGIF
-func composeGIF(frames []string, name string) {
-	outGIF := &gif.GIF{}
+// that would require decoupling play, saveGIFFrame and composeGIF
+func composeGIF(frames []string, name string) (int, []string, error) {
+	if len(frames) == 0 {
+		return 0, nil, fmt.Errorf("composeGIF: no frames to compose")
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	return writeGIF(f, frames, readGIFFrame)
+}
+
+// writeGIF writes the frames read by read to w as one animated GIF.
+// Frames are streamed: each one is decoded, re-encoded on its own and its
+// image block is spliced into the output before the next one is read.
+// This keeps memory flat no matter how many frames there are.
+// A frame that can't be read, or doesn't match the first one, is dropped
+// or replaced by the previous one as -on-frame-error says, and described
+// in the incidents; with abort it is an error.
+func writeGIF(out io.Writer, frames []string, read func(name string) (*image.Paletted, error)) (n int, incidents []string, err error) {
+	w := bufio.NewWriter(out)
+
+	var header, prev []byte
+	var buf bytes.Buffer
 	for _, file := range frames {
-		f, _ := os.Open(file)
-		inGIF, _ := gif.Decode(f)
-		f.Close()
+		block, err := encodeGIFFrame(&buf, file, read, header)
+		if err != nil {
+			switch {
+			case *onFrameError == "drop":
+				incidents = append(incidents, fmt.Sprintf("frame %s dropped from the GIF: %v", file, err))
+				continue
+			case *onFrameError == "repeat" && prev != nil:
+				incidents = append(incidents, fmt.Sprintf("frame %s replaced by previous frame in the GIF: %v", file, err))
+				block = prev
+			case *onFrameError == "repeat":
+				incidents = append(incidents, fmt.Sprintf("frame %s dropped from the GIF, no previous frame to repeat: %v", file, err))
+				continue
+			default:
+				return n, incidents, err
+			}
+		}
+
+		if header == nil {
+			// the first frame's header and color table become global
+			header = append([]byte(nil), buf.Bytes()[:gifHeaderLen(buf.Bytes())]...)
+			w.Write(header)
+			if len(frames) > 1 {
+				w.Write(gifLoopForever)
+			}
+			writeGIFComment(w, "tessellation "+version.Get().String())
+		}
+		w.Write(block)
+		prev = append(prev[:0], block...)
+		n++
+	}
+	if header == nil {
+		return 0, incidents, fmt.Errorf("composeGIF: none of the %d frames could be read", len(frames))
+	}
+	w.WriteByte(gifTrailer)
+
+	return n, incidents, w.Flush()
+}
+
+// encodeGIFFrame reads file with read and encodes it into buf, returning
+// its image block, minus the trailer. The header must match header, the
+// one of the first frame, unless that is nil.
+func encodeGIFFrame(buf *bytes.Buffer, file string, read func(name string) (*image.Paletted, error), header []byte) ([]byte, error) {
+	img, err := read(file)
+	if err != nil {
+		return nil, err
+	}
+
+	buf.Reset()
+	if err := gif.Encode(buf, img, nil); err != nil {
+		return nil, fmt.Errorf("composeGIF: %s: %v", file, err)
+	}
+	encoded := buf.Bytes()
+	n := gifHeaderLen(encoded)
+	if header != nil && !bytes.Equal(header, encoded[:n]) {
+		return nil, fmt.Errorf("composeGIF: %s: size or palette differs from first frame", file)
+	}
+	return encoded[n : len(encoded)-1], nil
+}
+
+// gifTrailer marks the end of a GIF stream.
+const gifTrailer = 0x3B
 
-		outGIF.Image = append(outGIF.Image, inGIF.(*image.Paletted)) // type assertion
-		outGIF.Delay = append(outGIF.Delay, 0)
+// gifLoopForever is the NETSCAPE2.0 application extension with a loop count of 0.
+var gifLoopForever = []byte{
+	0x21, 0xFF, 0x0B, 'N', 'E', 'T', 'S', 'C', 'A', 'P', 'E', '2', '.', '0',
+	0x03, 0x01, 0x00, 0x00, 0x00,
+}
+
+// writeGIFComment writes a comment extension, used to record what made the GIF.
+func writeGIFComment(w *bufio.Writer, comment string) {
+	w.Write([]byte{0x21, 0xFE})
+	for len(comment) > 0 {
+		n := len(comment)
+		if n > 255 {
+			n = 255
+		}
+		w.WriteByte(byte(n))
+		w.WriteString(comment[:n])
+		comment = comment[n:]
+	}
+	w.WriteByte(0) // block terminator
+}
+
+// gifHeaderLen returns the length of the header, logical screen descriptor
+// and global color table at the start of an encoded GIF.
+func gifHeaderLen(encoded []byte) int {
+	n := 6 + 7 // "GIF89a" and logical screen descriptor
+	if packed := encoded[10]; packed&0x80 != 0 {
+		n += 3 << (packed&0x07 + 1)
 	}
+	return n
+}
 
-	f, _ := os.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0600)
+// readGIFFrame decodes a single frame saved by saveGIFFrame.
+func readGIFFrame(name string) (*image.Paletted, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
 	defer f.Close()
-	gif.EncodeAll(f, outGIF)
+
+	inGIF, err := gif.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return inGIF.(*image.Paletted), nil // type assertion
 }
 
 // tilePrint is convenient for printing the tile to console.
@@ -0,0 +1,59 @@
+// Package version reports which build of tessellation is running.
+package version
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Info identifies the code a binary was built from.
+type Info struct {
+	// Version is the module version, "(devel)" for local builds.
+	Version string
+
+	// Revision is the VCS commit, empty if unknown.
+	Revision string
+
+	// Dirty is true if the working tree had uncommitted changes.
+	Dirty bool
+}
+
+// Get reads the build information embedded in the running binary.
+func Get() Info {
+	info := Info{Version: "unknown"}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	// go run of a single file builds no main module version
+	info.Version = bi.Main.Version
+	if info.Version == "" {
+		info.Version = "(devel)"
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.modified":
+			info.Dirty = s.Value == "true"
+		}
+	}
+
+	return info
+}
+
+// String formats the info on one line, e.g. "v1.2.0 (3f2a9c1, dirty)".
+func (i Info) String() string {
+	if i.Revision == "" {
+		return i.Version
+	}
+	rev := i.Revision
+	if len(rev) > 7 {
+		rev = rev[:7]
+	}
+	if i.Dirty {
+		return fmt.Sprintf("%s (%s, dirty)", i.Version, rev)
+	}
+	return fmt.Sprintf("%s (%s)", i.Version, rev)
+}